@@ -0,0 +1,315 @@
+package files
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os/user"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// POSIX ACL对应的扩展属性
+const (
+	XattrACLAccess  = "system.posix_acl_access"
+	XattrACLDefault = "system.posix_acl_default"
+)
+
+// ACL条目的类型
+type ACLTag uint16
+
+const (
+	ACLUserObj  ACLTag = 0x01 // 属主, user::
+	ACLUser     ACLTag = 0x02 // 指定用户, user:uid:
+	ACLGroupObj ACLTag = 0x04 // 属组, group::
+	ACLGroup    ACLTag = 0x08 // 指定用户组, group:gid:
+	ACLMask     ACLTag = 0x10 // 指定用户和用户组的权限上限, mask::
+	ACLOther    ACLTag = 0x20 // 其他用户, other::
+)
+
+const (
+	aclVersion     = 2
+	aclUndefinedID = 0xFFFFFFFF
+	aclHeaderSize  = 4
+	aclEntrySize   = 8
+)
+
+// ACL条目, Perm为rwx对应的4、2、1
+type ACLEntry struct {
+	Tag  ACLTag `json:"tag"`
+	ID   uint32 `json:"id"` // 只有ACLUser和ACLGroup使用
+	Perm uint16 `json:"perm"`
+}
+
+// POSIX ACL, 文本格式与getfacl一致, 如 user::rw-,user:1000:r--,group::r--,mask::r--,other::---
+type ACL []ACLEntry
+
+// 读取文件的访问ACL, 没有扩展ACL时返回nil
+func GetACL(path string) (ACL, error) {
+	return getACL(path, XattrACLAccess)
+}
+
+// 读取目录的默认ACL, 目录下新建的文件会继承该ACL; 没有默认ACL时返回nil
+func GetDefaultACL(path string) (ACL, error) {
+	return getACL(path, XattrACLDefault)
+}
+
+// 设置文件的访问ACL, 同时会更新文件的权限位; 有指定用户或用户组但缺少mask时自动计算
+func SetACL(path string, acl ACL) error {
+	return setACL(path, XattrACLAccess, acl)
+}
+
+// 设置目录的默认ACL
+func SetDefaultACL(path string, acl ACL) error {
+	return setACL(path, XattrACLDefault, acl)
+}
+
+// 删除目录的默认ACL
+func RemoveDefaultACL(path string) error {
+	err := RemoveXattr(path, XattrACLDefault)
+	if errors.Is(err, ErrXattrNotFound) {
+		return nil
+	}
+	return err
+}
+
+func getACL(path, name string) (ACL, error) {
+	value, err := GetXattr(path, name)
+	if errors.Is(err, ErrXattrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return decodeACL(value)
+}
+
+func setACL(path, name string, acl ACL) error {
+	value, err := acl.encode()
+	if err != nil {
+		return err
+	}
+	return SetXattr(path, name, value)
+}
+
+// 解析扩展属性中的二进制ACL
+func decodeACL(value []byte) (ACL, error) {
+	if len(value) < aclHeaderSize || (len(value)-aclHeaderSize)%aclEntrySize != 0 {
+		return nil, fmt.Errorf("无效的ACL数据, 长度: %d", len(value))
+	}
+	if version := binary.LittleEndian.Uint32(value); version != aclVersion {
+		return nil, fmt.Errorf("不支持的ACL版本: %d", version)
+	}
+	acl := make(ACL, 0, (len(value)-aclHeaderSize)/aclEntrySize)
+	for off := aclHeaderSize; off < len(value); off += aclEntrySize {
+		entry := ACLEntry{
+			Tag:  ACLTag(binary.LittleEndian.Uint16(value[off:])),
+			Perm: binary.LittleEndian.Uint16(value[off+2:]),
+			ID:   binary.LittleEndian.Uint32(value[off+4:]),
+		}
+		if entry.Tag != ACLUser && entry.Tag != ACLGroup {
+			entry.ID = 0
+		}
+		acl = append(acl, entry)
+	}
+	return acl, nil
+}
+
+// 编码为内核要求的格式: 条目按类型和ID排序, 必须包含属主、属组和其他用户
+func (acl ACL) encode() ([]byte, error) {
+	entries := make(ACL, len(acl))
+	copy(entries, acl)
+	var hasMask, hasNamed bool
+	required := map[ACLTag]bool{}
+	var groupPerm uint16
+	for _, entry := range entries {
+		switch entry.Tag {
+		case ACLUserObj, ACLGroupObj, ACLOther:
+			required[entry.Tag] = true
+		case ACLMask:
+			hasMask = true
+		case ACLUser, ACLGroup:
+			hasNamed = true
+		default:
+			return nil, fmt.Errorf("无效的ACL条目类型: %#x", uint16(entry.Tag))
+		}
+		if entry.Perm > 7 {
+			return nil, fmt.Errorf("无效的ACL权限: %d", entry.Perm)
+		}
+		if entry.Tag == ACLUser || entry.Tag == ACLGroup || entry.Tag == ACLGroupObj {
+			groupPerm |= entry.Perm
+		}
+	}
+	if len(required) != 3 {
+		return nil, fmt.Errorf("ACL必须包含user::、group::和other::条目")
+	}
+	if hasNamed && !hasMask {
+		entries = append(entries, ACLEntry{Tag: ACLMask, Perm: groupPerm})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Tag != entries[j].Tag {
+			return entries[i].Tag < entries[j].Tag
+		}
+		return entries[i].ID < entries[j].ID
+	})
+
+	buf := make([]byte, aclHeaderSize+len(entries)*aclEntrySize)
+	binary.LittleEndian.PutUint32(buf, aclVersion)
+	for i, entry := range entries {
+		off := aclHeaderSize + i*aclEntrySize
+		if i > 0 && entries[i-1].Tag == entry.Tag && entries[i-1].ID == entry.ID {
+			return nil, fmt.Errorf("ACL条目重复: %s", entry)
+		}
+		id := entry.ID
+		if entry.Tag != ACLUser && entry.Tag != ACLGroup {
+			id = aclUndefinedID
+		}
+		binary.LittleEndian.PutUint16(buf[off:], uint16(entry.Tag))
+		binary.LittleEndian.PutUint16(buf[off+2:], entry.Perm)
+		binary.LittleEndian.PutUint32(buf[off+4:], id)
+	}
+	return buf, nil
+}
+
+/*
+解析getfacl风格的ACL文本, 条目之间用逗号或换行分隔, 忽略#开头的注释
+用户和用户组可以是名称或数字ID, 如 user:nginx:r-x、g:1000:rw-
+*/
+func ParseACL(text string) (ACL, error) {
+	var acl ACL
+	for _, field := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == '\n' }) {
+		field = strings.TrimSpace(field)
+		if field == "" || strings.HasPrefix(field, "#") {
+			continue
+		}
+		entry, err := parseACLEntry(field)
+		if err != nil {
+			return nil, err
+		}
+		acl = append(acl, entry)
+	}
+	return acl, nil
+}
+
+func parseACLEntry(field string) (ACLEntry, error) {
+	var entry ACLEntry
+	parts := strings.Split(field, ":")
+	if len(parts) != 3 {
+		return entry, fmt.Errorf("无效的ACL条目: %s", field)
+	}
+	tag, qualifier, perm := parts[0], parts[1], parts[2]
+	var err error
+	switch tag {
+	case "user", "u":
+		entry.Tag = ACLUserObj
+		if qualifier != "" {
+			entry.Tag = ACLUser
+			entry.ID, err = lookupACLID(qualifier, false)
+		}
+	case "group", "g":
+		entry.Tag = ACLGroupObj
+		if qualifier != "" {
+			entry.Tag = ACLGroup
+			entry.ID, err = lookupACLID(qualifier, true)
+		}
+	case "mask", "m":
+		entry.Tag = ACLMask
+	case "other", "o":
+		entry.Tag = ACLOther
+	default:
+		return entry, fmt.Errorf("无效的ACL条目类型: %s", field)
+	}
+	if err != nil {
+		return entry, err
+	}
+	if (entry.Tag == ACLMask || entry.Tag == ACLOther) && qualifier != "" {
+		return entry, fmt.Errorf("无效的ACL条目: %s", field)
+	}
+	if entry.Perm, err = parseACLPerm(perm); err != nil {
+		return entry, fmt.Errorf("无效的ACL权限: %s", field)
+	}
+	return entry, nil
+}
+
+func lookupACLID(name string, group bool) (uint32, error) {
+	if id, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return uint32(id), nil
+	}
+	var idStr string
+	if group {
+		g, err := user.LookupGroup(name)
+		if err != nil {
+			return 0, err
+		}
+		idStr = g.Gid
+	} else {
+		u, err := user.Lookup(name)
+		if err != nil {
+			return 0, err
+		}
+		idStr = u.Uid
+	}
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	return uint32(id), err
+}
+
+// 支持 rwx、r-x 以及八进制数字 5
+func parseACLPerm(s string) (uint16, error) {
+	if len(s) == 1 && s[0] >= '0' && s[0] <= '7' {
+		return uint16(s[0] - '0'), nil
+	}
+	var perm uint16
+	for _, c := range s {
+		switch c {
+		case 'r':
+			perm |= 4
+		case 'w':
+			perm |= 2
+		case 'x':
+			perm |= 1
+		case '-':
+		default:
+			return 0, fmt.Errorf("无效的权限字符: %c", c)
+		}
+	}
+	return perm, nil
+}
+
+func (e ACLEntry) String() string {
+	var tag, qualifier string
+	switch e.Tag {
+	case ACLUserObj:
+		tag = "user"
+	case ACLUser:
+		tag, qualifier = "user", strconv.FormatUint(uint64(e.ID), 10)
+	case ACLGroupObj:
+		tag = "group"
+	case ACLGroup:
+		tag, qualifier = "group", strconv.FormatUint(uint64(e.ID), 10)
+	case ACLMask:
+		tag = "mask"
+	case ACLOther:
+		tag = "other"
+	default:
+		tag = fmt.Sprintf("%#x", uint16(e.Tag))
+	}
+	perm := []byte("---")
+	if e.Perm&4 != 0 {
+		perm[0] = 'r'
+	}
+	if e.Perm&2 != 0 {
+		perm[1] = 'w'
+	}
+	if e.Perm&1 != 0 {
+		perm[2] = 'x'
+	}
+	return tag + ":" + qualifier + ":" + string(perm)
+}
+
+func (acl ACL) String() string {
+	entries := make([]string, len(acl))
+	for i, entry := range acl {
+		entries[i] = entry.String()
+	}
+	return strings.Join(entries, ",")
+}
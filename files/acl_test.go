@@ -0,0 +1,71 @@
+package files
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseACL(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr bool
+	}{
+		{"基本条目", "user::rw-,group::r--,other::---", "user::rw-,group::r--,other::---", false},
+		{"缩写和八进制", "u::7\ng:1000:5\n# 注释\nm::rx,o::0", "user::rwx,group:1000:r-x,mask::r-x,other::---", false},
+		{"无效的类型", "x::rw-", "", true},
+		{"mask不能带ID", "mask:1:rw-", "", true},
+		{"无效的权限", "user::rwz", "", true},
+		{"字段数量错误", "user:rw-", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acl, err := ParseACL(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseACL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := acl.String(); !tt.wantErr && got != tt.want {
+				t.Errorf("ParseACL() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestACLEncode(t *testing.T) {
+	acl, err := ParseACL("other::r--,group:100:rw-,user::rwx,user:1000:r--,group::r--")
+	mustDo(t, err)
+	value, err := acl.encode()
+	if err != nil {
+		t.Fatalf("encode() error = %v", err)
+	}
+	got, err := decodeACL(value)
+	if err != nil {
+		t.Fatalf("decodeACL() error = %v", err)
+	}
+	// 按类型和ID排序, 缺少的mask按指定用户、用户组和属组权限的并集补全
+	want := ACL{
+		{Tag: ACLUserObj, Perm: 7},
+		{Tag: ACLUser, ID: 1000, Perm: 4},
+		{Tag: ACLGroupObj, Perm: 4},
+		{Tag: ACLGroup, ID: 100, Perm: 6},
+		{Tag: ACLMask, Perm: 6},
+		{Tag: ACLOther, Perm: 4},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decodeACL(encode()) = %s, want %s", got, want)
+	}
+
+	for _, text := range []string{"user::rwx,group::r--", "user::rwx,user::r--,group::r--,other::---"} {
+		acl, err := ParseACL(text)
+		mustDo(t, err)
+		if _, err = acl.encode(); err == nil {
+			t.Errorf("encode(%s) error = nil, want error", text)
+		}
+	}
+	for _, value := range [][]byte{{2, 0, 0}, {1, 0, 0, 0}, {2, 0, 0, 0, 1}} {
+		if _, err := decodeACL(value); err == nil {
+			t.Errorf("decodeACL(%v) error = nil, want error", value)
+		}
+	}
+}
@@ -0,0 +1,726 @@
+package files
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// 压缩包格式
+type ArchiveFormat string
+
+const (
+	FormatUnknown ArchiveFormat = ""
+	FormatTar     ArchiveFormat = "tar"
+	FormatTarGz   ArchiveFormat = "tar.gz"
+	FormatTarBz2  ArchiveFormat = "tar.bz2" // 只支持解压
+	FormatTarXz   ArchiveFormat = "tar.xz"
+	FormatTarZst  ArchiveFormat = "tar.zst"
+	FormatZip     ArchiveFormat = "zip"
+)
+
+// PAX格式中保存扩展属性的记录前缀, 与GNU tar、bsdtar兼容
+const paxXattrPrefix = "SCHILY.xattr."
+
+// 创建压缩包的选项
+type ArchiveOptions struct {
+	// 压缩级别, 0表示使用各格式的默认级别; tar.gz和zip取值1-9, tar.zst取值1-22, tar.xz不支持调整
+	Level int
+	// gitignore风格的包含规则, 为空时包含全部文件; 规则相对于source目录匹配
+	Include []string
+	// gitignore风格的排除规则, 优先级高于包含规则
+	Exclude []string
+	// 替换压缩包内的顶层路径, 为空时使用source的名称, 为"."时条目直接位于压缩包根目录
+	Prefix string
+	// 进度回调, 总字节数为待打包文件的大小之和
+	Progress ProgressFunc
+	// 可重现模式: 条目按路径排序, 修改时间置为Unix纪元(zip为1980-01-01 UTC), 属主置为0, 权限统一为0644/0755, 不记录扩展属性;
+	// 相同的输入总是生成字节完全相同的压缩包
+	Reproducible bool
+}
+
+// 各压缩格式的魔数
+var archiveMagics = []struct {
+	format ArchiveFormat
+	offset int
+	magic  []byte
+}{
+	{FormatTarGz, 0, []byte{0x1f, 0x8b}},
+	{FormatTarBz2, 0, []byte("BZh")},
+	{FormatTarXz, 0, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}},
+	{FormatTarZst, 0, []byte{0x28, 0xb5, 0x2f, 0xfd}},
+	{FormatZip, 0, []byte("PK\x03\x04")},
+	{FormatZip, 0, []byte("PK\x05\x06")}, // 空zip包
+	{FormatTar, 257, []byte("ustar")},
+}
+
+// 根据文件头部的魔数判断压缩包格式, 不依赖文件扩展名
+func DetectArchiveFormat(archivePath string) (ArchiveFormat, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return FormatUnknown, err
+	}
+	defer f.Close()
+	return detectArchiveFormat(f)
+}
+
+func detectArchiveFormat(r io.Reader) (ArchiveFormat, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return FormatUnknown, err
+	}
+	head = head[:n]
+	for _, m := range archiveMagics {
+		if len(head) >= m.offset+len(m.magic) && bytes.Equal(head[m.offset:m.offset+len(m.magic)], m.magic) {
+			return m.format, nil
+		}
+	}
+	// 没有ustar标记的老式v7格式tar包, 通过头部校验和判断
+	if len(head) == 512 && validTarChecksum(head) {
+		return FormatTar, nil
+	}
+	return FormatUnknown, fmt.Errorf("无法识别的压缩包格式")
+}
+
+// 校验tar头部的校验和: 148-155字节按空格计算后, 全部字节之和与记录值相等
+func validTarChecksum(block []byte) bool {
+	field := strings.TrimRight(strings.TrimSpace(string(block[148:156])), "\x00")
+	var recorded int64
+	if _, err := fmt.Sscanf(field, "%o", &recorded); err != nil {
+		return false
+	}
+	var sum int64
+	for i, b := range block {
+		if i >= 148 && i < 156 {
+			b = ' '
+		}
+		sum += int64(b)
+	}
+	return sum == recorded
+}
+
+// 根据文件扩展名推断压缩包格式, 用于创建压缩包时选择格式
+func ArchiveFormatFromName(name string) ArchiveFormat {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return FormatTarGz
+	case strings.HasSuffix(lower, ".tar.bz2"), strings.HasSuffix(lower, ".tbz2"):
+		return FormatTarBz2
+	case strings.HasSuffix(lower, ".tar.xz"), strings.HasSuffix(lower, ".txz"):
+		return FormatTarXz
+	case strings.HasSuffix(lower, ".tar.zst"), strings.HasSuffix(lower, ".tzst"):
+		return FormatTarZst
+	case strings.HasSuffix(lower, ".tar"):
+		return FormatTar
+	case strings.HasSuffix(lower, ".zip"):
+		return FormatZip
+	}
+	return FormatUnknown
+}
+
+// 将文件或目录打包为指定格式的压缩包, 目录会以其名称作为压缩包内的顶层目录
+func CreateArchive(source, target string, format ArchiveFormat, opts ArchiveOptions) error {
+	return CreateArchiveContext(context.Background(), source, target, format, opts)
+}
+
+// 支持取消的打包, 先写入临时文件, 成功后再重命名为target, 失败或取消时不会留下不完整的压缩包
+func CreateArchiveContext(ctx context.Context, source, target string, format ArchiveFormat, opts ArchiveOptions) error {
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	out, err := createTempSibling(target, 0666)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(out)
+	aw, err := newArchiveWriter(bw, format, opts)
+	if err != nil {
+		return finishTempSibling(out, target, err)
+	}
+	if err = writeArchiveTree(ctx, aw, source, info, opts); err != nil {
+		aw.Close()
+		return finishTempSibling(out, target, err)
+	}
+	if err = aw.Close(); err == nil {
+		err = bw.Flush()
+	}
+	return finishTempSibling(out, target, err)
+}
+
+// 解压压缩包, 根据魔数自动识别格式
+func ExtractArchive(archivePath, dest string, opts ExtractOptions) error {
+	return ExtractArchiveContext(context.Background(), archivePath, dest, opts)
+}
+
+// 支持取消的解压, 每个文件先写入临时文件再重命名, 取消时已解压的完整文件会保留
+func ExtractArchiveContext(ctx context.Context, archivePath, dest string, opts ExtractOptions) error {
+	return extractArchive(ctx, archivePath, dest, FormatUnknown, opts)
+}
+
+// 打开压缩包并解压, format为FormatUnknown时自动识别
+func extractArchive(ctx context.Context, archivePath, dest string, format ArchiveFormat, opts ExtractOptions) error {
+	if opts.CheckSpace {
+		if err := checkExtractSpace(archivePath, dest, format); err != nil {
+			return err
+		}
+	}
+	ar, err := openArchive(archivePath, format)
+	if err != nil {
+		return err
+	}
+	defer ar.Close()
+	ex, err := newExtractor(ctx, dest, ar, opts)
+	if err != nil {
+		return err
+	}
+	return ex.extractAll(ar.entries)
+}
+
+// 统计压缩包解压后的总大小和条目数, 检查目标文件系统的剩余空间
+func checkExtractSpace(archivePath, dest string, format ArchiveFormat) error {
+	ar, err := openArchive(archivePath, format)
+	if err != nil {
+		return err
+	}
+	defer ar.Close()
+	var size, count int64
+	for {
+		hdr, err := ar.entries.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if hdr.Typeflag == tar.TypeReg || hdr.Typeflag == tar.TypeRegA {
+			size += hdr.Size
+		}
+		count++
+	}
+	return CheckDiskSpace(dest, size, count)
+}
+
+// 已打开的压缩包
+type archiveReader struct {
+	format  ArchiveFormat
+	size    int64 // 压缩包文件大小
+	entries entryReader
+	closers []func() error
+	// 返回已读取和总的字节数, 用于解压进度
+	progress func() (done, total int64)
+}
+
+func (a *archiveReader) Close() error {
+	var err error
+	for i := len(a.closers) - 1; i >= 0; i-- {
+		if closeErr := a.closers[i](); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// 打开压缩包, 返回统一的条目读取接口, format为FormatUnknown时自动识别
+func openArchive(archivePath string, format ArchiveFormat) (*archiveReader, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	ar := &archiveReader{closers: []func() error{f.Close}}
+	info, err := f.Stat()
+	if err != nil {
+		ar.Close()
+		return nil, err
+	}
+	ar.size = info.Size()
+	if format == FormatUnknown {
+		if format, err = detectArchiveFormat(f); err != nil {
+			ar.Close()
+			return nil, fmt.Errorf("%s: %v", archivePath, err)
+		}
+		if _, err = f.Seek(0, io.SeekStart); err != nil {
+			ar.Close()
+			return nil, err
+		}
+	}
+	ar.format = format
+
+	if format == FormatZip {
+		zr, err := zip.NewReader(f, ar.size)
+		if err != nil {
+			ar.Close()
+			return nil, err
+		}
+		zer := &zipEntryReader{files: zr.File}
+		var total int64
+		for _, zf := range zr.File {
+			total += int64(zf.UncompressedSize64)
+		}
+		ar.entries = zer
+		ar.progress = func() (int64, int64) {
+			return zer.read, total
+		}
+		return ar, nil
+	}
+
+	// tar系列格式以读取压缩包文件的字节数作为进度
+	counter := &countingReader{r: f}
+	ar.progress = func() (int64, int64) {
+		return counter.n, ar.size
+	}
+	var r io.Reader = bufio.NewReader(counter)
+	switch format {
+	case FormatTar:
+	case FormatTarGz:
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			ar.Close()
+			return nil, err
+		}
+		ar.closers = append(ar.closers, gr.Close)
+		r = gr
+	case FormatTarBz2:
+		r = bzip2.NewReader(r)
+	case FormatTarXz:
+		if r, err = xz.NewReader(r); err != nil {
+			ar.Close()
+			return nil, err
+		}
+	case FormatTarZst:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			ar.Close()
+			return nil, err
+		}
+		ar.closers = append(ar.closers, func() error {
+			zr.Close()
+			return nil
+		})
+		r = zr
+	default:
+		ar.Close()
+		return nil, fmt.Errorf("不支持的压缩包格式: %s", format)
+	}
+	ar.entries = tar.NewReader(r)
+	return ar, nil
+}
+
+// 将zip包适配为与tar.Reader一致的条目读取接口
+type zipEntryReader struct {
+	files   []*zip.File
+	index   int
+	current io.ReadCloser
+	read    int64 // 已读取的解压后字节数
+}
+
+func (z *zipEntryReader) Next() (*tar.Header, error) {
+	if z.current != nil {
+		z.current.Close()
+		z.current = nil
+	}
+	if z.index >= len(z.files) {
+		return nil, io.EOF
+	}
+	zf := z.files[z.index]
+	z.index++
+
+	var link string
+	if zf.Mode()&os.ModeSymlink != 0 {
+		// zip中软链接的目标保存在文件内容中
+		rc, err := zf.Open()
+		if err != nil {
+			return nil, err
+		}
+		target, err := io.ReadAll(io.LimitReader(rc, 4096))
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		link = string(target)
+	}
+	hdr, err := tar.FileInfoHeader(zf.FileInfo(), link)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", zf.Name, err)
+	}
+	hdr.Name = zf.Name
+	if hdr.Typeflag == tar.TypeReg {
+		if z.current, err = zf.Open(); err != nil {
+			return nil, err
+		}
+	}
+	return hdr, nil
+}
+
+func (z *zipEntryReader) Read(p []byte) (int, error) {
+	if z.current == nil {
+		return 0, io.EOF
+	}
+	n, err := z.current.Read(p)
+	z.read += int64(n)
+	return n, err
+}
+
+// 统一tar和zip写入的接口
+type archiveWriter interface {
+	// 写入条目头, 返回的writer用于写入普通文件内容, 返回nil表示该条目被跳过
+	WriteHeader(hdr *tar.Header) (io.Writer, error)
+	// 用于识别硬链接的记录表, 不支持硬链接的格式返回nil
+	links() hardLinkTracker
+	Close() error
+}
+
+func newArchiveWriter(w io.Writer, format ArchiveFormat, opts ArchiveOptions) (archiveWriter, error) {
+	if format == FormatZip {
+		zw := zip.NewWriter(w)
+		if opts.Level > 0 {
+			zw.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+				return flate.NewWriter(out, opts.Level)
+			})
+		}
+		return &zipArchiveWriter{zw: zw, reproducible: opts.Reproducible}, nil
+	}
+
+	tw := &tarArchiveWriter{hardLinks: hardLinkTracker{}}
+	switch format {
+	case FormatTar:
+		tw.tw = tar.NewWriter(w)
+	case FormatTarGz:
+		level := gzip.DefaultCompression
+		if opts.Level > 0 {
+			level = opts.Level
+		}
+		gw, err := gzip.NewWriterLevel(w, level)
+		if err != nil {
+			return nil, err
+		}
+		tw.tw, tw.compressor = tar.NewWriter(gw), gw
+	case FormatTarXz:
+		xw, err := xz.NewWriter(w)
+		if err != nil {
+			return nil, err
+		}
+		tw.tw, tw.compressor = tar.NewWriter(xw), xw
+	case FormatTarZst:
+		encOpts := []zstd.EOption{}
+		if opts.Reproducible {
+			encOpts = append(encOpts, zstd.WithEncoderConcurrency(1))
+		}
+		if opts.Level > 0 {
+			encOpts = append(encOpts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(opts.Level)))
+		}
+		zw, err := zstd.NewWriter(w, encOpts...)
+		if err != nil {
+			return nil, err
+		}
+		tw.tw, tw.compressor = tar.NewWriter(zw), zw
+	default:
+		return nil, fmt.Errorf("不支持创建该格式的压缩包: %s", format)
+	}
+	return tw, nil
+}
+
+type tarArchiveWriter struct {
+	tw         *tar.Writer
+	compressor io.WriteCloser
+	hardLinks  hardLinkTracker
+}
+
+func (t *tarArchiveWriter) WriteHeader(hdr *tar.Header) (io.Writer, error) {
+	if err := t.tw.WriteHeader(hdr); err != nil {
+		return nil, err
+	}
+	return t.tw, nil
+}
+
+func (t *tarArchiveWriter) links() hardLinkTracker {
+	return t.hardLinks
+}
+
+func (t *tarArchiveWriter) Close() error {
+	err := t.tw.Close()
+	if t.compressor != nil {
+		if closeErr := t.compressor.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+type zipArchiveWriter struct {
+	zw           *zip.Writer
+	reproducible bool
+}
+
+// 可重现模式下zip条目的修改时间; zip的DOS时间从1980年开始且不带时区, 不能使用Unix纪元
+var reproducibleZipTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func (z *zipArchiveWriter) WriteHeader(hdr *tar.Header) (io.Writer, error) {
+	switch hdr.Typeflag {
+	case tar.TypeDir, tar.TypeReg, tar.TypeSymlink:
+	default:
+		// zip不支持设备文件、FIFO等类型
+		return nil, nil
+	}
+	fh, err := zip.FileInfoHeader(hdr.FileInfo())
+	if err != nil {
+		return nil, err
+	}
+	fh.Name = hdr.Name
+	if z.reproducible {
+		// 使用UTC时间, DOS时间字段不受构建主机时区影响
+		fh.Modified = reproducibleZipTime
+	}
+	if hdr.Typeflag == tar.TypeReg {
+		fh.Method = zip.Deflate
+	} else {
+		fh.Method = zip.Store
+	}
+	w, err := z.zw.CreateHeader(fh)
+	if err != nil {
+		return nil, err
+	}
+	if hdr.Typeflag == tar.TypeSymlink {
+		_, err = io.WriteString(w, hdr.Linkname)
+		return nil, err
+	}
+	return w, nil
+}
+
+func (z *zipArchiveWriter) links() hardLinkTracker {
+	return nil
+}
+
+func (z *zipArchiveWriter) Close() error {
+	return z.zw.Close()
+}
+
+// 待写入压缩包的文件
+type archiveItem struct {
+	path string
+	rel  string // 相对于source的路径, 以/分隔, source本身为"."
+	info os.FileInfo
+}
+
+// 遍历source并按照选项写入压缩包
+func writeArchiveTree(ctx context.Context, aw archiveWriter, source string, sourceInfo os.FileInfo, opts ArchiveOptions) error {
+	items, err := collectArchiveItems(source, sourceInfo, opts)
+	if err != nil {
+		return err
+	}
+	var tracker *progressTracker
+	if opts.Progress != nil {
+		var total int64
+		for _, item := range items {
+			if item.info.Mode().IsRegular() {
+				total += item.info.Size()
+			}
+		}
+		tracker = newProgressTracker(opts.Progress, total)
+	}
+	base := opts.Prefix
+	if base == "" || (base == "." && !sourceInfo.IsDir()) {
+		// 单个文件直接以文件名写入
+		base = filepath.Base(source)
+	}
+	links := aw.links()
+	for _, item := range items {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		name := path.Join(base, item.rel)
+		if base == "." && item.rel == "." {
+			continue
+		}
+		header, err := newTarHeader(item.path, name, item.info, links)
+		if err != nil {
+			return err
+		}
+		if opts.Reproducible {
+			normalizeTarHeader(header)
+		}
+		w, err := aw.WriteHeader(header)
+		if err != nil {
+			return err
+		}
+		// 目录、链接、设备文件等只有头信息
+		if w == nil || header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err = copyFileTo(ctx, w, item.path, tracker); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFileTo(ctx context.Context, w io.Writer, filePath string, tracker *progressTracker) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = copyContext(ctx, w, file, func(n int64) {
+		tracker.add(n, filePath)
+	})
+	return err
+}
+
+/*
+收集需要写入压缩包的文件
+1. 被排除的目录整个跳过
+2. 有包含规则时, 只保留匹配的文件以及它们的上级目录
+3. 可重现模式下按相对路径排序, 保证上级目录总是在子条目之前
+*/
+func collectArchiveItems(source string, sourceInfo os.FileInfo, opts ArchiveOptions) ([]archiveItem, error) {
+	filter, err := newPathFilter(opts.Include, opts.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	if !sourceInfo.IsDir() {
+		if !filter.keepFile(sourceInfo.Name()) {
+			return nil, nil
+		}
+		return []archiveItem{{path: source, rel: ".", info: sourceInfo}}, nil
+	}
+
+	var walked []archiveItem
+	keep := map[string]bool{".": true}
+	err = filepath.Walk(source, func(filePath string, info os.FileInfo, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		rel, err := filepath.Rel(source, filePath)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel != "." {
+			if info.IsDir() {
+				if filter.skipDir(rel) {
+					return filepath.SkipDir
+				}
+				if filter.include.Empty() || filter.include.Match(rel, true) {
+					keep[rel] = true
+				}
+			} else if filter.keepFile(rel) {
+				// 保留文件的同时保留全部上级目录
+				for dir := rel; dir != "."; dir = path.Dir(dir) {
+					keep[dir] = true
+				}
+			}
+		}
+		walked = append(walked, archiveItem{path: filePath, rel: rel, info: info})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	items := walked[:0]
+	for _, item := range walked {
+		if keep[item.rel] {
+			items = append(items, item)
+		}
+	}
+	if opts.Reproducible {
+		sort.SliceStable(items, func(i, j int) bool {
+			return items[i].rel < items[j].rel
+		})
+	}
+	return items, nil
+}
+
+// 可重现模式下去掉与构建环境相关的元数据
+func normalizeTarHeader(header *tar.Header) {
+	header.ModTime = time.Unix(0, 0)
+	header.AccessTime = time.Time{}
+	header.ChangeTime = time.Time{}
+	header.Uid, header.Gid = 0, 0
+	header.Uname, header.Gname = "", ""
+	header.PAXRecords = nil
+	switch header.Typeflag {
+	case tar.TypeDir:
+		header.Mode = 0755
+	case tar.TypeSymlink:
+		header.Mode = 0777
+	default:
+		if header.Mode&0111 != 0 {
+			header.Mode = 0755
+		} else {
+			header.Mode = 0644
+		}
+	}
+}
+
+// 记录已经写入压缩包的多链接文件, key为设备号和inode号, value为首次写入时的条目名称
+type hardLinkTracker map[[2]uint64]string
+
+/*
+根据文件信息生成tar头
+1. 软链接记录链接目标
+2. 同一个inode第二次出现时写为硬链接条目
+3. 扩展属性写入PAX记录
+4. 使用PAX格式以保留纳秒精度的修改时间, 不记录访问时间和状态变更时间
+*/
+func newTarHeader(path, name string, info os.FileInfo, links hardLinkTracker) (*tar.Header, error) {
+	var link string
+	var err error
+	if info.Mode()&os.ModeSymlink != 0 {
+		if link, err = os.Readlink(path); err != nil {
+			return nil, err
+		}
+	}
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return nil, err
+	}
+	header.Name = filepath.ToSlash(name)
+	if info.IsDir() && !strings.HasSuffix(header.Name, "/") {
+		header.Name += "/"
+	}
+	header.Format = tar.FormatPAX
+	header.AccessTime = time.Time{}
+	header.ChangeTime = time.Time{}
+
+	if info.Mode().IsRegular() && links != nil {
+		if dev, ino, nlink, ok := fileInode(info); ok && nlink > 1 {
+			key := [2]uint64{dev, ino}
+			if first, seen := links[key]; seen {
+				header.Typeflag = tar.TypeLink
+				header.Linkname = first
+				header.Size = 0
+			} else {
+				links[key] = header.Name
+			}
+		}
+	}
+
+	xattrs, err := readXattrs(path)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range xattrs {
+		if header.PAXRecords == nil {
+			header.PAXRecords = make(map[string]string, len(xattrs))
+		}
+		header.PAXRecords[paxXattrPrefix+key] = string(value)
+	}
+	return header, nil
+}
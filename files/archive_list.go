@@ -0,0 +1,176 @@
+package files
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// 文件类型
+type FileType string
+
+const (
+	FileTypeFile     FileType = "file"
+	FileTypeDir      FileType = "dir"
+	FileTypeSymlink  FileType = "symlink"
+	FileTypeHardlink FileType = "hardlink" // 只出现在压缩包中
+	FileTypeFifo     FileType = "fifo"
+	FileTypeDevice   FileType = "device"
+	FileTypeOther    FileType = "other"
+)
+
+var (
+	// 遍历回调返回StopWalk时提前结束遍历, 遍历函数本身返回nil
+	StopWalk = errors.New("stop walk")
+	// 压缩包中没有匹配的条目
+	ErrEntryNotFound = errors.New("压缩包中没有匹配的条目")
+)
+
+// 压缩包中的条目信息
+type ArchiveEntry struct {
+	Name     string      `json:"name"`
+	Size     int64       `json:"size"`
+	Mode     os.FileMode `json:"mode"`
+	Type     FileType    `json:"type"`
+	ModTime  time.Time   `json:"mod_time"`
+	Linkname string      `json:"linkname,omitempty"` // 软链接或硬链接的目标
+}
+
+func newArchiveEntry(hdr *tar.Header) ArchiveEntry {
+	entry := ArchiveEntry{
+		Name:     hdr.Name,
+		Size:     hdr.Size,
+		Mode:     hdr.FileInfo().Mode(),
+		ModTime:  hdr.ModTime,
+		Linkname: hdr.Linkname,
+	}
+	switch hdr.Typeflag {
+	case tar.TypeReg, tar.TypeRegA:
+		entry.Type = FileTypeFile
+	case tar.TypeDir:
+		entry.Type = FileTypeDir
+	case tar.TypeSymlink:
+		entry.Type = FileTypeSymlink
+	case tar.TypeLink:
+		entry.Type = FileTypeHardlink
+		entry.Size = 0
+	case tar.TypeFifo:
+		entry.Type = FileTypeFifo
+	case tar.TypeChar, tar.TypeBlock:
+		entry.Type = FileTypeDevice
+	default:
+		entry.Type = FileTypeOther
+	}
+	return entry
+}
+
+// 去掉条目名称开头的./和结尾的/, 用于匹配
+func normalizeEntryName(name string) string {
+	name = strings.TrimPrefix(name, "./")
+	return strings.TrimSuffix(name, "/")
+}
+
+/*
+流式遍历压缩包中的条目, 不会将内容解压到磁盘, 支持所有可以解压的格式
+普通文件可以在回调中通过reader读取内容, 其他类型的条目reader读取不到数据
+回调返回StopWalk时提前结束遍历
+*/
+func WalkArchive(archivePath string, fn func(entry ArchiveEntry, r io.Reader) error) error {
+	ar, err := openArchive(archivePath, FormatUnknown)
+	if err != nil {
+		return err
+	}
+	defer ar.Close()
+	for {
+		hdr, err := ar.entries.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+		var r io.Reader = ar.entries
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			r = strings.NewReader("")
+		}
+		if err = fn(newArchiveEntry(hdr), r); err == StopWalk {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// 列出压缩包中的全部条目
+func ListArchive(archivePath string) ([]ArchiveEntry, error) {
+	var entries []ArchiveEntry
+	err := WalkArchive(archivePath, func(entry ArchiveEntry, r io.Reader) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	return entries, err
+}
+
+/*
+将匹配的普通文件内容依次写入w, 效果与 tar -xOf 相同, 返回匹配的条目名称
+patterns为gitignore风格的规则, 如 conf/app.yml、*.yml、conf/
+没有任何条目匹配时返回ErrEntryNotFound
+*/
+func ExtractArchiveEntriesTo(archivePath string, patterns []string, w io.Writer) ([]string, error) {
+	var matched []string
+	matcher, err := NewPathMatcher(patterns)
+	if err != nil {
+		return matched, err
+	}
+	err = WalkArchive(archivePath, func(entry ArchiveEntry, r io.Reader) error {
+		if entry.Type != FileTypeFile || !matcher.Match(normalizeEntryName(entry.Name), false) {
+			return nil
+		}
+		matched = append(matched, entry.Name)
+		_, err := io.Copy(w, r)
+		return err
+	})
+	if err == nil && len(matched) == 0 {
+		err = ErrEntryNotFound
+	}
+	return matched, err
+}
+
+/*
+只解压匹配的条目到dest目录, 返回解压的条目名称
+patterns为gitignore风格的规则, 匹配目录时解压整个目录; 解压选项与ExtractArchive一致
+没有任何条目匹配时返回ErrEntryNotFound
+*/
+func ExtractArchiveEntries(archivePath, dest string, patterns []string, opts ExtractOptions) ([]string, error) {
+	var matched []string
+	matcher, err := NewPathMatcher(patterns)
+	if err != nil {
+		return matched, err
+	}
+	ar, err := openArchive(archivePath, FormatUnknown)
+	if err != nil {
+		return matched, err
+	}
+	defer ar.Close()
+	ex, err := newExtractor(context.Background(), dest, ar, opts)
+	if err != nil {
+		return matched, err
+	}
+	ex.filter = func(hdr *tar.Header) bool {
+		if !matcher.Match(normalizeEntryName(hdr.Name), hdr.Typeflag == tar.TypeDir) {
+			return false
+		}
+		matched = append(matched, hdr.Name)
+		return true
+	}
+	if err = ex.extractAll(ar.entries); err == nil && len(matched) == 0 {
+		err = ErrEntryNotFound
+	}
+	return matched, err
+}
@@ -0,0 +1,81 @@
+package files
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestArchiveEntries(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "app")
+	writeTree(t, source, map[string]string{
+		"conf/app.yml": "port: 8080\n",
+		"conf/dev.yml": "debug: true\n",
+		"run.sh":       "#!/bin/sh\n",
+	})
+	mustDo(t, os.Symlink("run.sh", filepath.Join(source, "start.sh")))
+
+	for _, format := range []ArchiveFormat{FormatTarGz, FormatZip} {
+		t.Run(string(format), func(t *testing.T) {
+			archivePath := filepath.Join(dir, "app."+string(format))
+			mustDo(t, CreateArchive(source, archivePath, format, ArchiveOptions{Prefix: ".", Reproducible: true}))
+
+			entries, err := ListArchive(archivePath)
+			if err != nil {
+				t.Fatalf("ListArchive() error = %v", err)
+			}
+			types := map[string]FileType{}
+			for _, entry := range entries {
+				types[normalizeEntryName(entry.Name)] = entry.Type
+				if entry.Type == FileTypeSymlink && entry.Linkname != "run.sh" {
+					t.Errorf("%s Linkname = %s, want run.sh", entry.Name, entry.Linkname)
+				}
+			}
+			wantTypes := map[string]FileType{
+				"conf": FileTypeDir, "conf/app.yml": FileTypeFile, "conf/dev.yml": FileTypeFile,
+				"run.sh": FileTypeFile, "start.sh": FileTypeSymlink,
+			}
+			delete(types, "")
+			if !reflect.DeepEqual(types, wantTypes) {
+				t.Errorf("ListArchive() = %v, want %v", types, wantTypes)
+			}
+
+			// 回调返回StopWalk时提前结束
+			count := 0
+			err = WalkArchive(archivePath, func(entry ArchiveEntry, r io.Reader) error {
+				count++
+				return StopWalk
+			})
+			if err != nil || count != 1 {
+				t.Errorf("WalkArchive() = %v, 回调%d次, want nil, 1次", err, count)
+			}
+
+			var buf bytes.Buffer
+			matched, err := ExtractArchiveEntriesTo(archivePath, []string{"conf/app.yml"}, &buf)
+			if err != nil || len(matched) != 1 || buf.String() != "port: 8080\n" {
+				t.Errorf("ExtractArchiveEntriesTo() = %v, %q, %v", matched, buf.String(), err)
+			}
+
+			dest := filepath.Join(dir, "out-"+string(format))
+			if _, err = ExtractArchiveEntries(archivePath, dest, []string{"conf/"}, DefaultSecureExtractOptions()); err != nil {
+				t.Fatalf("ExtractArchiveEntries() error = %v", err)
+			}
+			want := map[string]string{"conf/app.yml": "port: 8080\n", "conf/dev.yml": "debug: true\n"}
+			if got := readTree(t, dest); !reflect.DeepEqual(got, want) {
+				t.Errorf("ExtractArchiveEntries() 解压结果 = %v, want %v", got, want)
+			}
+
+			if _, err = ExtractArchiveEntriesTo(archivePath, []string{"missing.txt"}, io.Discard); !errors.Is(err, ErrEntryNotFound) {
+				t.Errorf("ExtractArchiveEntriesTo() error = %v, want %v", err, ErrEntryNotFound)
+			}
+			if _, err = ExtractArchiveEntries(archivePath, dest, []string{"missing.txt"}, ExtractOptions{}); !errors.Is(err, ErrEntryNotFound) {
+				t.Errorf("ExtractArchiveEntries() error = %v, want %v", err, ErrEntryNotFound)
+			}
+		})
+	}
+}
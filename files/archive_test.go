@@ -0,0 +1,139 @@
+package files
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 可重现模式下, 不同时区、不同修改时间的构建环境生成字节完全相同的压缩包
+func TestCreateArchiveReproducible(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "app")
+	mustDo(t, os.MkdirAll(filepath.Join(source, "conf"), 0755))
+	mustDo(t, os.WriteFile(filepath.Join(source, "conf", "app.yml"), []byte("port: 8080\n"), 0640))
+	mustDo(t, os.WriteFile(filepath.Join(source, "run.sh"), []byte("#!/bin/sh\n"), 0750))
+
+	local := time.Local
+	defer func() { time.Local = local }()
+	build := func(format ArchiveFormat, name, tz string, mtime time.Time) []byte {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			t.Skip("缺少时区数据:", err)
+		}
+		time.Local = loc
+		for _, p := range []string{source, filepath.Join(source, "conf"), filepath.Join(source, "conf", "app.yml"), filepath.Join(source, "run.sh")} {
+			mustDo(t, os.Chtimes(p, mtime, mtime))
+		}
+		target := filepath.Join(dir, tz[:4]+"-"+name)
+		mustDo(t, CreateArchive(source, target, format, ArchiveOptions{Reproducible: true}))
+		data, err := os.ReadFile(target)
+		mustDo(t, err)
+		return data
+	}
+
+	for _, tt := range []struct {
+		format ArchiveFormat
+		name   string
+	}{{FormatZip, "app.zip"}, {FormatTarGz, "app.tar.gz"}} {
+		first := build(tt.format, tt.name, "Asia/Shanghai", time.Date(2020, 5, 6, 7, 8, 9, 0, time.UTC))
+		second := build(tt.format, tt.name, "America/New_York", time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC))
+		if !bytes.Equal(first, second) {
+			t.Errorf("%s: 两次构建的压缩包内容不同", tt.name)
+		}
+	}
+
+	zr, err := zip.OpenReader(filepath.Join(dir, "Amer-app.zip"))
+	mustDo(t, err)
+	defer zr.Close()
+	for _, f := range zr.File {
+		if !f.Modified.Equal(reproducibleZipTime) {
+			t.Errorf("%s Modified = %v, want %v", f.Name, f.Modified, reproducibleZipTime)
+		}
+	}
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "app")
+	tree := map[string]string{
+		"conf/app.yml": "port: 8080\n",
+		"run.sh":       "#!/bin/sh\n",
+		"data/空文件":     "",
+	}
+	writeTree(t, source, tree)
+
+	for _, format := range []ArchiveFormat{FormatTar, FormatTarGz, FormatTarXz, FormatTarZst, FormatZip} {
+		t.Run(string(format), func(t *testing.T) {
+			target := filepath.Join(dir, "app."+string(format))
+			if err := CreateArchive(source, target, format, ArchiveOptions{}); err != nil {
+				t.Fatalf("CreateArchive() error = %v", err)
+			}
+			if got, err := DetectArchiveFormat(target); err != nil || got != format {
+				t.Errorf("DetectArchiveFormat() = %s, %v, want %s", got, err, format)
+			}
+			if got := ArchiveFormatFromName(target); got != format {
+				t.Errorf("ArchiveFormatFromName() = %s, want %s", got, format)
+			}
+			dest := filepath.Join(dir, "out-"+string(format))
+			if err := ExtractArchive(target, dest, DefaultSecureExtractOptions()); err != nil {
+				t.Fatalf("ExtractArchive() error = %v", err)
+			}
+			if got := readTree(t, filepath.Join(dest, "app")); !reflect.DeepEqual(got, tree) {
+				t.Errorf("解压结果 = %v, want %v", got, tree)
+			}
+		})
+	}
+
+	if _, err := DetectArchiveFormat(filepath.Join(source, "run.sh")); err == nil {
+		t.Errorf("DetectArchiveFormat(非压缩包) error = nil, want error")
+	}
+}
+
+func TestCreateArchiveFilter(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "app")
+	writeTree(t, source, map[string]string{
+		"conf/app.yml":  "port: 8080\n",
+		"conf/dev.yml":  "debug: true\n",
+		"bin/app":       "binary",
+		"logs/app.log":  "log",
+		"cache/tmp.dat": "tmp",
+	})
+	tests := []struct {
+		name string
+		opts ArchiveOptions
+		want []string
+	}{
+		{"默认使用目录名作为顶层路径", ArchiveOptions{Exclude: []string{"logs/", "cache/"}}, []string{"app/bin/app", "app/conf/app.yml", "app/conf/dev.yml"}},
+		{"包含和排除", ArchiveOptions{Include: []string{"conf/", "bin/"}, Exclude: []string{"dev.yml"}, Prefix: "release-1.0"}, []string{"release-1.0/bin/app", "release-1.0/conf/app.yml"}},
+		{"条目位于根目录", ArchiveOptions{Include: []string{"*.yml"}, Prefix: "."}, []string{"conf/app.yml", "conf/dev.yml"}},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := filepath.Join(dir, fmt.Sprintf("%d.tar.gz", i))
+			if err := CreateArchive(source, target, FormatTarGz, tt.opts); err != nil {
+				t.Fatalf("CreateArchive() error = %v", err)
+			}
+			entries, err := ListArchive(target)
+			mustDo(t, err)
+			var got []string
+			for _, entry := range entries {
+				if entry.Type == FileTypeFile {
+					got = append(got, strings.TrimPrefix(entry.Name, "./"))
+				}
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("压缩包中的文件 = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
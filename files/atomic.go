@@ -0,0 +1,158 @@
+package files
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// 原子写入选项
+type AtomicWriteOptions struct {
+	// 新建文件的权限, 受umask影响, 0表示0666
+	Perm os.FileMode
+	// 目标文件已存在时沿用其权限(包括setuid、setgid、sticky), 优先于Perm
+	KeepMode bool
+	// 目标文件已存在时沿用其属主, 通常需要root权限
+	KeepOwner bool
+}
+
+/*
+原子写入的文件
+1. 在目标文件所在目录创建临时文件, 所有写入都发生在临时文件上
+2. Commit时fsync临时文件, 重命名覆盖目标文件, 再fsync所在目录
+3. 在Commit之前崩溃或调用Abort, 目标文件保持原样
+*/
+type AtomicFile struct {
+	*os.File
+	path string
+	opts AtomicWriteOptions
+	done bool
+}
+
+// 创建原子写入的文件, 使用完毕后必须调用Commit或Abort; 可以在Commit之后defer调用Abort, 此时不会做任何事
+func NewAtomicFile(path string, opts AtomicWriteOptions) (*AtomicFile, error) {
+	perm := opts.Perm
+	if perm == 0 {
+		perm = 0666
+	}
+	tmp, err := createTempSibling(path, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &AtomicFile{File: tmp, path: path, opts: opts}, nil
+}
+
+// 写入完成, 将临时文件替换为目标文件
+func (f *AtomicFile) Commit() error {
+	if f.done {
+		return errors.New("原子写入的文件已经提交或放弃")
+	}
+	f.done = true
+	err := f.File.Sync()
+	if err == nil {
+		err = f.copyTargetMeta()
+	}
+	if err = finishTempSibling(f.File, f.path, err); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(f.path))
+}
+
+// 放弃写入并删除临时文件, 已经Commit时不做任何事
+func (f *AtomicFile) Abort() error {
+	if f.done {
+		return nil
+	}
+	f.done = true
+	f.File.Close()
+	return os.Remove(f.File.Name())
+}
+
+// 按选项沿用已存在的目标文件的权限和属主
+func (f *AtomicFile) copyTargetMeta() error {
+	if !f.opts.KeepMode && !f.opts.KeepOwner {
+		return nil
+	}
+	info, err := os.Stat(f.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	// 先chown再chmod, chown会清除setuid位
+	if f.opts.KeepOwner {
+		if uid, gid, ok := fileOwner(info); ok {
+			if err = f.File.Chown(uid, gid); err != nil {
+				return err
+			}
+		}
+	}
+	if f.opts.KeepMode {
+		return f.File.Chmod(info.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky))
+	}
+	return nil
+}
+
+// 原子写入数据到文件, 中途崩溃时不会留下内容不完整的文件
+func AtomicWriteFile(filePath string, data []byte, opts AtomicWriteOptions) error {
+	f, err := NewAtomicFile(filePath, opts)
+	if err != nil {
+		return err
+	}
+	defer f.Abort()
+	if _, err = f.Write(data); err != nil {
+		return err
+	}
+	return f.Commit()
+}
+
+// fsync目录, 保证重命名操作落盘; Windows不支持对目录fsync
+func syncDir(dirPath string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	dir, err := os.Open(dirPath)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+/*
+在目标文件所在目录创建临时文件, 写入完成后再重命名为目标文件, 失败或取消时删除临时文件,
+保证不会留下写了一半的文件; 权限与os.OpenFile一致, 受umask影响
+*/
+func createTempSibling(path string, perm os.FileMode) (*os.File, error) {
+	dir, base := filepath.Split(path)
+	for {
+		suffix := make([]byte, 6)
+		if _, err := rand.Read(suffix); err != nil {
+			return nil, err
+		}
+		tmpPath := filepath.Join(dir, "."+base+".tmp-"+hex.EncodeToString(suffix))
+		f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, perm)
+		if os.IsExist(err) {
+			continue
+		}
+		return f, err
+	}
+}
+
+// 将写好的临时文件重命名为目标文件, err不为nil时删除临时文件
+func finishTempSibling(tmp *os.File, path string, err error) error {
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
@@ -0,0 +1,46 @@
+package files
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAtomicWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.conf")
+	mustDo(t, AtomicWriteFile(path, []byte("v1"), AtomicWriteOptions{Perm: 0600}))
+	info, err := os.Stat(path)
+	mustDo(t, err)
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("新建文件的权限 = %v, want %v", info.Mode().Perm(), os.FileMode(0600))
+	}
+
+	// 沿用已存在的目标文件的权限
+	mustDo(t, os.Chmod(path, 0640))
+	mustDo(t, AtomicWriteFile(path, []byte("v2"), AtomicWriteOptions{KeepMode: true, KeepOwner: true}))
+	if info, err = os.Stat(path); err != nil || info.Mode().Perm() != 0640 {
+		t.Errorf("KeepMode 权限 = %v, %v, want %v", info.Mode().Perm(), err, os.FileMode(0640))
+	}
+	if content, _ := os.ReadFile(path); string(content) != "v2" {
+		t.Errorf("文件内容 = %q, want v2", content)
+	}
+
+	// 放弃写入时目标文件保持原样, 临时文件被删除
+	f, err := NewAtomicFile(path, AtomicWriteOptions{})
+	mustDo(t, err)
+	_, err = f.WriteString("v3")
+	mustDo(t, err)
+	mustDo(t, f.Abort())
+	if content, _ := os.ReadFile(path); string(content) != "v2" {
+		t.Errorf("Abort后文件内容 = %q, want v2", content)
+	}
+	if err = f.Commit(); err == nil {
+		t.Errorf("Abort后Commit() error = nil, want error")
+	}
+	entries, err := os.ReadDir(dir)
+	mustDo(t, err)
+	if len(entries) != 1 {
+		t.Errorf("目录中有%d个文件, 临时文件没有删除", len(entries))
+	}
+}
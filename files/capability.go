@@ -0,0 +1,300 @@
+package files
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 文件能力对应的扩展属性
+const XattrCapability = "security.capability"
+
+// Linux能力, 编号与内核一致
+type Capability uint
+
+const (
+	CapChown Capability = iota
+	CapDacOverride
+	CapDacReadSearch
+	CapFowner
+	CapFsetid
+	CapKill
+	CapSetgid
+	CapSetuid
+	CapSetpcap
+	CapLinuxImmutable
+	CapNetBindService
+	CapNetBroadcast
+	CapNetAdmin
+	CapNetRaw
+	CapIpcLock
+	CapIpcOwner
+	CapSysModule
+	CapSysRawio
+	CapSysChroot
+	CapSysPtrace
+	CapSysPacct
+	CapSysAdmin
+	CapSysBoot
+	CapSysNice
+	CapSysResource
+	CapSysTime
+	CapSysTtyConfig
+	CapMknod
+	CapLease
+	CapAuditWrite
+	CapAuditControl
+	CapSetfcap
+	CapMacOverride
+	CapMacAdmin
+	CapSyslog
+	CapWakeAlarm
+	CapBlockSuspend
+	CapAuditRead
+	CapPerfmon
+	CapBpf
+	CapCheckpointRestore
+)
+
+var capabilityNames = []string{
+	"cap_chown", "cap_dac_override", "cap_dac_read_search", "cap_fowner", "cap_fsetid",
+	"cap_kill", "cap_setgid", "cap_setuid", "cap_setpcap", "cap_linux_immutable",
+	"cap_net_bind_service", "cap_net_broadcast", "cap_net_admin", "cap_net_raw", "cap_ipc_lock",
+	"cap_ipc_owner", "cap_sys_module", "cap_sys_rawio", "cap_sys_chroot", "cap_sys_ptrace",
+	"cap_sys_pacct", "cap_sys_admin", "cap_sys_boot", "cap_sys_nice", "cap_sys_resource",
+	"cap_sys_time", "cap_sys_tty_config", "cap_mknod", "cap_lease", "cap_audit_write",
+	"cap_audit_control", "cap_setfcap", "cap_mac_override", "cap_mac_admin", "cap_syslog",
+	"cap_wake_alarm", "cap_block_suspend", "cap_audit_read", "cap_perfmon", "cap_bpf",
+	"cap_checkpoint_restore",
+}
+
+// 名称格式与libcap一致, 如 cap_net_bind_service; 未知的编号输出为数字
+func (c Capability) String() string {
+	if int(c) < len(capabilityNames) {
+		return capabilityNames[c]
+	}
+	return fmt.Sprintf("%d", uint(c))
+}
+
+// 解析能力名称, 不区分大小写, 可以省略cap_前缀
+func ParseCapability(name string) (Capability, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if !strings.HasPrefix(name, "cap_") {
+		name = "cap_" + name
+	}
+	for i, n := range capabilityNames {
+		if n == name {
+			return Capability(i), nil
+		}
+	}
+	return 0, fmt.Errorf("未知的能力: %s", name)
+}
+
+// 能力集合, 第N位表示编号为N的能力
+type CapSet uint64
+
+// 全部已知的能力
+var capSetAll = CapSet(1)<<len(capabilityNames) - 1
+
+func NewCapSet(caps ...Capability) CapSet {
+	var set CapSet
+	for _, c := range caps {
+		set |= 1 << c
+	}
+	return set
+}
+
+func (s CapSet) Has(c Capability) bool {
+	return c < 64 && s&(1<<c) != 0
+}
+
+// 按编号从小到大列出集合中的能力
+func (s CapSet) List() []Capability {
+	var caps []Capability
+	for c := Capability(0); c < 64; c++ {
+		if s.Has(c) {
+			caps = append(caps, c)
+		}
+	}
+	return caps
+}
+
+/*
+文件能力, 执行该文件时进程获得的能力
+1. Effective为true时Permitted中的能力在执行后立即生效, 对于不感知能力的程序必须设置
+2. RootID不为0时写入v3格式, 只在该用户命名空间中生效
+*/
+type FileCaps struct {
+	Permitted   CapSet `json:"permitted"`
+	Inheritable CapSet `json:"inheritable"`
+	Effective   bool   `json:"effective"`
+	RootID      uint32 `json:"root_id,omitempty"`
+}
+
+const (
+	vfsCapRevisionMask   = 0xFF000000
+	vfsCapFlagsEffective = 0x000001
+	vfsCapRevision2      = 0x02000000
+	vfsCapRevision3      = 0x03000000
+	vfsCapV2Size         = 20
+	vfsCapV3Size         = 24
+)
+
+// 读取文件能力, 没有设置时返回nil
+func GetFileCaps(path string) (*FileCaps, error) {
+	value, err := GetXattr(path, XattrCapability)
+	if errors.Is(err, ErrXattrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return decodeFileCaps(value)
+}
+
+// 设置文件能力, 需要CAP_SETFCAP权限; 修改文件内容或属主后内核会清除文件能力, 需要重新设置
+func SetFileCaps(path string, caps FileCaps) error {
+	return SetXattr(path, XattrCapability, caps.encode())
+}
+
+// 清除文件能力, 没有设置时不报错
+func RemoveFileCaps(path string) error {
+	err := RemoveXattr(path, XattrCapability)
+	if errors.Is(err, ErrXattrNotFound) {
+		return nil
+	}
+	return err
+}
+
+func decodeFileCaps(value []byte) (*FileCaps, error) {
+	if len(value) < 4 {
+		return nil, fmt.Errorf("无效的文件能力数据, 长度: %d", len(value))
+	}
+	magic := binary.LittleEndian.Uint32(value)
+	caps := &FileCaps{Effective: magic&vfsCapFlagsEffective != 0}
+	switch magic & vfsCapRevisionMask {
+	case vfsCapRevision2:
+		if len(value) != vfsCapV2Size {
+			return nil, fmt.Errorf("无效的文件能力数据, 长度: %d", len(value))
+		}
+	case vfsCapRevision3:
+		if len(value) != vfsCapV3Size {
+			return nil, fmt.Errorf("无效的文件能力数据, 长度: %d", len(value))
+		}
+		caps.RootID = binary.LittleEndian.Uint32(value[20:])
+	default:
+		return nil, fmt.Errorf("不支持的文件能力版本: %#x", magic&vfsCapRevisionMask)
+	}
+	// 64位的能力集合拆成低32位和高32位两组存储
+	caps.Permitted = CapSet(binary.LittleEndian.Uint32(value[4:])) | CapSet(binary.LittleEndian.Uint32(value[12:]))<<32
+	caps.Inheritable = CapSet(binary.LittleEndian.Uint32(value[8:])) | CapSet(binary.LittleEndian.Uint32(value[16:]))<<32
+	return caps, nil
+}
+
+func (c FileCaps) encode() []byte {
+	magic, size := uint32(vfsCapRevision2), vfsCapV2Size
+	if c.RootID != 0 {
+		magic, size = vfsCapRevision3, vfsCapV3Size
+	}
+	if c.Effective {
+		magic |= vfsCapFlagsEffective
+	}
+	buf := make([]byte, size)
+	binary.LittleEndian.PutUint32(buf, magic)
+	binary.LittleEndian.PutUint32(buf[4:], uint32(c.Permitted))
+	binary.LittleEndian.PutUint32(buf[8:], uint32(c.Inheritable))
+	binary.LittleEndian.PutUint32(buf[12:], uint32(c.Permitted>>32))
+	binary.LittleEndian.PutUint32(buf[16:], uint32(c.Inheritable>>32))
+	if c.RootID != 0 {
+		binary.LittleEndian.PutUint32(buf[20:], c.RootID)
+	}
+	return buf
+}
+
+/*
+解析setcap风格的文本, 如 cap_net_bind_service+ep、cap_net_raw,cap_net_admin+p cap_chown+i
+1. 多个子句用空格分隔, 每个子句为能力列表加上一个或多个 +、-、= 操作和 e、i、p 标志
+2. 能力列表为空或为all时表示全部能力, 如 =ep
+3. 文件能力只有一个生效标志, 解析完成后仍有能力带e标志时Effective为true
+*/
+func ParseFileCaps(text string) (*FileCaps, error) {
+	caps := &FileCaps{}
+	var effective CapSet
+	for _, clause := range strings.Fields(text) {
+		idx := strings.IndexAny(clause, "+-=")
+		if idx < 0 {
+			return nil, fmt.Errorf("无效的文件能力: %s", clause)
+		}
+		set := capSetAll
+		if names := clause[:idx]; names != "" && names != "all" {
+			set = 0
+			for _, name := range strings.Split(names, ",") {
+				c, err := ParseCapability(name)
+				if err != nil {
+					return nil, err
+				}
+				set |= 1 << c
+			}
+		}
+		ops := clause[idx:]
+		for len(ops) > 0 {
+			op := ops[0]
+			end := strings.IndexAny(ops[1:], "+-=") + 1
+			if end == 0 {
+				end = len(ops)
+			}
+			flags := ops[1:end]
+			ops = ops[end:]
+			if op == '=' {
+				caps.Permitted &^= set
+				caps.Inheritable &^= set
+				effective &^= set
+			}
+			for _, flag := range flags {
+				var target *CapSet
+				switch flag {
+				case 'p':
+					target = &caps.Permitted
+				case 'i':
+					target = &caps.Inheritable
+				case 'e':
+					target = &effective
+				default:
+					return nil, fmt.Errorf("无效的文件能力标志: %s", clause)
+				}
+				if op == '-' {
+					*target &^= set
+				} else {
+					*target |= set
+				}
+			}
+		}
+	}
+	caps.Effective = effective != 0
+	return caps, nil
+}
+
+// 输出setcap风格的文本, 相同标志的能力合并为一个子句
+func (c FileCaps) String() string {
+	groups := map[string][]string{}
+	for _, capability := range (c.Permitted | c.Inheritable).List() {
+		flags := ""
+		if c.Effective {
+			flags += "e"
+		}
+		if c.Inheritable.Has(capability) {
+			flags += "i"
+		}
+		if c.Permitted.Has(capability) {
+			flags += "p"
+		}
+		groups[flags] = append(groups[flags], capability.String())
+	}
+	clauses := make([]string, 0, len(groups))
+	for flags, names := range groups {
+		clauses = append(clauses, strings.Join(names, ",")+"+"+flags)
+	}
+	sort.Strings(clauses)
+	return strings.Join(clauses, " ")
+}
@@ -0,0 +1,78 @@
+package files
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFileCaps(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    FileCaps
+		wantErr bool
+	}{
+		{"单个能力", "cap_net_bind_service+ep", FileCaps{Permitted: NewCapSet(CapNetBindService), Effective: true}, false},
+		{"多个子句", "cap_net_raw,cap_net_admin+p cap_chown+i", FileCaps{Permitted: NewCapSet(CapNetRaw, CapNetAdmin), Inheritable: NewCapSet(CapChown)}, false},
+		{"全部能力再去掉一个", "=ep cap_sys_admin-ep", FileCaps{Permitted: capSetAll &^ NewCapSet(CapSysAdmin), Effective: true}, false},
+		{"省略前缀并忽略大小写", "NET_RAW+p", FileCaps{Permitted: NewCapSet(CapNetRaw)}, false},
+		{"未知的能力", "cap_foo+p", FileCaps{}, true},
+		{"无效的标志", "cap_chown+x", FileCaps{}, true},
+		{"缺少操作", "cap_chown", FileCaps{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFileCaps(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseFileCaps() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("ParseFileCaps() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileCapsEncode(t *testing.T) {
+	tests := []struct {
+		name string
+		caps FileCaps
+		size int
+	}{
+		{"v2", FileCaps{Permitted: NewCapSet(CapNetBindService, CapCheckpointRestore), Effective: true}, vfsCapV2Size},
+		{"v3", FileCaps{Permitted: NewCapSet(CapNetRaw), Inheritable: NewCapSet(CapBpf), RootID: 100000}, vfsCapV3Size},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value := tt.caps.encode()
+			if len(value) != tt.size {
+				t.Errorf("encode() 长度 = %d, want %d", len(value), tt.size)
+			}
+			got, err := decodeFileCaps(value)
+			if err != nil {
+				t.Fatalf("decodeFileCaps() error = %v", err)
+			}
+			if !reflect.DeepEqual(*got, tt.caps) {
+				t.Errorf("decodeFileCaps(encode()) = %+v, want %+v", *got, tt.caps)
+			}
+		})
+	}
+	if _, err := decodeFileCaps([]byte{0, 0, 0, 1}); err == nil {
+		t.Errorf("decodeFileCaps(v1) error = nil, want error")
+	}
+}
+
+func TestFileCapsString(t *testing.T) {
+	caps := FileCaps{Permitted: NewCapSet(CapNetRaw, CapNetAdmin, CapChown), Inheritable: NewCapSet(CapChown), Effective: true}
+	if got, want := caps.String(), "cap_chown+eip cap_net_admin,cap_net_raw+ep"; got != want {
+		t.Errorf("String() = %s, want %s", got, want)
+	}
+	parsed, err := ParseFileCaps(caps.String())
+	mustDo(t, err)
+	if !reflect.DeepEqual(*parsed, caps) {
+		t.Errorf("ParseFileCaps(String()) = %+v, want %+v", *parsed, caps)
+	}
+	if got := Capability(63).String(); got != "63" {
+		t.Errorf("Capability(63).String() = %s, want 63", got)
+	}
+}
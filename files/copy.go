@@ -0,0 +1,452 @@
+package files
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// 软链接的处理方式
+type SymlinkPolicy int
+
+const (
+	SymlinkCopy   SymlinkPolicy = iota // 复制链接本身
+	SymlinkFollow                      // 跟随链接, 复制其指向的内容
+	SymlinkSkip                        // 跳过链接
+)
+
+// 目标文件已存在时的处理方式
+type OverwritePolicy int
+
+const (
+	OverwriteAlways  OverwritePolicy = iota // 总是覆盖
+	OverwriteNever                          // 从不覆盖
+	OverwriteIfNewer                        // 源文件的修改时间更新时才覆盖
+)
+
+// 拷贝目录的选项
+type CopyOptions struct {
+	// 保留完整的权限位(包括setuid、setgid、sticky), 关闭时新文件沿用源文件权限并受umask影响
+	PreserveMode bool
+	// 保留属主, 通常需要root权限
+	PreserveOwner bool
+	// 保留访问时间和修改时间
+	PreserveTimes bool
+	// 保留全部扩展属性, 包括ACL、SELinux标签和文件能力
+	PreserveXattrs bool
+	// 只保留POSIX ACL(包括目录的默认ACL), PreserveXattrs开启时无需设置
+	PreserveACL bool
+	// 只保留SELinux安全上下文, PreserveXattrs开启时无需设置
+	PreserveSELinux bool
+	// 只保留文件能力, 需要CAP_SETFCAP权限; PreserveXattrs开启时无需设置
+	PreserveCapabilities bool
+	// 软链接的处理方式, 默认复制链接本身
+	Symlinks SymlinkPolicy
+	// 目标已存在时的处理方式, 默认总是覆盖
+	Overwrite OverwritePolicy
+	// gitignore风格的包含规则, 为空时包含全部文件; 规则相对于源目录匹配
+	Include []string
+	// gitignore风格的排除规则, 优先级高于包含规则
+	Exclude []string
+	// 遇到错误时记录并继续拷贝其他文件, 全部结束后以CopyErrors返回; 关闭时遇到第一个错误即停止
+	ContinueOnError bool
+	// 进度回调, 总字节数为按软链接策略和过滤规则需要拷贝的文件大小
+	Progress ProgressFunc
+	// 开始拷贝前检查目标文件系统的剩余空间和inode, 不足时返回ErrInsufficientSpace或ErrInsufficientInodes
+	CheckSpace bool
+}
+
+// 拷贝单个路径失败的错误
+type CopyError struct {
+	Path string
+	Err  error
+}
+
+func (e *CopyError) Error() string {
+	return fmt.Sprintf("拷贝失败! 路径: %s 错误信息: %v", e.Path, e.Err)
+}
+
+func (e *CopyError) Unwrap() error {
+	return e.Err
+}
+
+// ContinueOnError模式下收集到的全部错误
+type CopyErrors []*CopyError
+
+func (e CopyErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return fmt.Sprintf("拷贝过程中发生%d个错误: %s", len(e), strings.Join(messages, "; "))
+}
+
+// 拷贝文件夹, 保留权限和属主, 软链接复制为链接
+func CopyDirectory(scrDir, dstDir string) error {
+	return CopyDirectoryWithOptions(context.Background(), scrDir, dstDir, CopyOptions{PreserveMode: true, PreserveOwner: true})
+}
+
+// 支持取消和进度回调的拷贝文件夹, 进度的总字节数为源目录下全部文件的大小, 软链接不计入
+func CopyDirectoryWithContext(ctx context.Context, scrDir, dstDir string, progress ProgressFunc) error {
+	return CopyDirectoryWithOptions(ctx, scrDir, dstDir, CopyOptions{PreserveMode: true, PreserveOwner: true, Progress: progress})
+}
+
+// 按选项拷贝文件夹
+func CopyDirectoryWithOptions(ctx context.Context, srcDir, dstDir string, opts CopyOptions) error {
+	return copyDirectory(ctx, srcDir, dstDir, opts, nil)
+}
+
+// renderer不为nil时渲染匹配的模板文件, 其余文件正常拷贝
+func copyDirectory(ctx context.Context, srcDir, dstDir string, opts CopyOptions, renderer *templateRenderer) error {
+	info, err := os.Stat(srcDir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return &CopyError{Path: srcDir, Err: fmt.Errorf("不是目录")}
+	}
+	filter, err := newPathFilter(opts.Include, opts.Exclude)
+	if err != nil {
+		return err
+	}
+	if opts.CheckSpace {
+		// 按源目录实际占用的空间估算, 不考虑包含规则和目标中已存在的文件
+		usage, err := CalcDiskUsage(ctx, srcDir, DiskUsageOptions{Exclude: opts.Exclude})
+		if err != nil {
+			return err
+		}
+		if err = CheckDiskSpace(dstDir, usage.AllocatedSize, usage.Files+usage.Dirs); err != nil {
+			return err
+		}
+	}
+	c := &treeCopier{ctx: ctx, opts: opts, filter: filter, renderer: renderer, visited: map[[2]uint64]bool{}}
+	if opts.Progress != nil {
+		total, err := c.dirSize(srcDir, ".", info)
+		if err != nil {
+			return err
+		}
+		c.tracker = newProgressTracker(opts.Progress, total)
+	}
+	if err = c.copyDir(srcDir, dstDir, ".", info, nil); err != nil {
+		return err
+	}
+	if len(c.errs) > 0 {
+		return c.errs
+	}
+	return nil
+}
+
+type treeCopier struct {
+	ctx     context.Context
+	opts    CopyOptions
+	filter  *pathFilter
+	tracker *progressTracker
+	errs    CopyErrors
+	visited map[[2]uint64]bool // 跟随软链接时正在拷贝的目录, 用于发现循环
+	// 模板渲染, 只有RenderTemplateDir使用
+	renderer *templateRenderer
+}
+
+// 记录错误, ContinueOnError模式下返回nil继续拷贝; 取消总是立即返回
+func (c *treeCopier) fail(path string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if ctxErr := c.ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	copyErr, ok := err.(*CopyError)
+	if !ok {
+		copyErr = &CopyError{Path: path, Err: err}
+	}
+	if c.opts.ContinueOnError {
+		c.errs = append(c.errs, copyErr)
+		return nil
+	}
+	return copyErr
+}
+
+/*
+拷贝目录
+1. 有包含规则时目标目录在第一次需要写入时才创建, 不会留下空的目录结构
+2. 目录的元数据在其内容拷贝完成后再设置, 避免写入子文件修改目录的时间, 或只读目录无法写入
+*/
+func (c *treeCopier) copyDir(src, dst, rel string, info os.FileInfo, ensureParent func() error) error {
+	if dev, ino, _, ok := fileInode(info); ok {
+		key := [2]uint64{dev, ino}
+		if c.visited[key] {
+			return c.fail(src, fmt.Errorf("跟随软链接时发现循环"))
+		}
+		c.visited[key] = true
+		defer delete(c.visited, key)
+	}
+
+	created := false
+	ensure := func() error {
+		if created {
+			return nil
+		}
+		if ensureParent != nil {
+			if err := ensureParent(); err != nil {
+				return err
+			}
+		}
+		perm := os.FileMode(0777)
+		if c.opts.PreserveMode {
+			perm = info.Mode().Perm() | 0700
+		}
+		if err := os.MkdirAll(dst, perm); err != nil {
+			return &CopyError{Path: dst, Err: err}
+		}
+		created = true
+		return nil
+	}
+	if rel == "." || c.filter.include.Empty() || c.filter.include.Match(rel, true) {
+		if err := ensure(); err != nil {
+			return c.fail(dst, err)
+		}
+	}
+
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return c.fail(src, err)
+	}
+	for _, entry := range entries {
+		if err := c.ctx.Err(); err != nil {
+			return err
+		}
+		if err := c.copyEntry(filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name()), path.Join(rel, entry.Name()), ensure); err != nil {
+			return err
+		}
+	}
+	if created {
+		return c.fail(dst, c.applyMeta(src, dst, info))
+	}
+	return nil
+}
+
+// 按软链接策略和过滤规则获取要拷贝的条目, 返回nil表示跳过
+func (c *treeCopier) entryInfo(src, rel string) (os.FileInfo, error) {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		switch c.opts.Symlinks {
+		case SymlinkSkip:
+			return nil, nil
+		case SymlinkFollow:
+			if info, err = os.Stat(src); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if info.IsDir() {
+		if c.filter.skipDir(rel) {
+			return nil, nil
+		}
+	} else if !c.filter.keepFile(rel) {
+		return nil, nil
+	}
+	return info, nil
+}
+
+/*
+统计目录下需要拷贝的普通文件大小, 作为进度的总字节数
+1. 与拷贝使用相同的软链接策略和过滤规则, 复制为链接的软链接不计入其指向的文件
+2. 不考虑目标中已存在而跳过的文件; 无法读取的路径不计入, 由拷贝时报告错误
+*/
+func (c *treeCopier) dirSize(src, rel string, info os.FileInfo) (int64, error) {
+	if dev, ino, _, ok := fileInode(info); ok {
+		key := [2]uint64{dev, ino}
+		if c.visited[key] {
+			return 0, nil
+		}
+		c.visited[key] = true
+		defer delete(c.visited, key)
+	}
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return 0, nil
+	}
+	var total int64
+	for _, entry := range entries {
+		if err := c.ctx.Err(); err != nil {
+			return total, err
+		}
+		childSrc, childRel := filepath.Join(src, entry.Name()), path.Join(rel, entry.Name())
+		info, err := c.entryInfo(childSrc, childRel)
+		if err != nil || info == nil {
+			continue
+		}
+		if info.IsDir() {
+			size, err := c.dirSize(childSrc, childRel, info)
+			if err != nil {
+				return total, err
+			}
+			total += size
+		} else if info.Mode().IsRegular() && (c.renderer != nil && c.renderer.match(childRel) || !c.shadowed(childSrc, childRel)) {
+			total += info.Size()
+		}
+	}
+	return total, nil
+}
+
+// 渲染模板时, 与模板的渲染结果同名的文件不再拷贝
+func (c *treeCopier) shadowed(src, rel string) bool {
+	return c.renderer != nil && c.renderer.shadowed(src, rel, c.filter)
+}
+
+func (c *treeCopier) copyEntry(src, dst, rel string, ensureParent func() error) error {
+	info, err := c.entryInfo(src, rel)
+	if err != nil {
+		return c.fail(src, err)
+	}
+	if info == nil {
+		return nil
+	}
+	if info.IsDir() {
+		return c.copyDir(src, dst, rel, info, ensureParent)
+	}
+	render := c.renderer != nil && info.Mode().IsRegular() && c.renderer.match(rel)
+	if render {
+		dst = c.renderer.target(dst)
+	} else if c.shadowed(src, rel) {
+		return nil
+	}
+	// 模板的修改时间与渲染数据无关, 渲染结果不按OverwriteIfNewer跳过
+	if !render || c.opts.Overwrite != OverwriteIfNewer {
+		if skip, err := c.skipExisting(dst, info); err != nil || skip {
+			return c.fail(dst, err)
+		}
+	}
+	if err = ensureParent(); err != nil {
+		return c.fail(dst, err)
+	}
+
+	switch {
+	case render:
+		if err = c.renderer.render(src, dst, rel, info); err == nil {
+			c.tracker.add(info.Size(), src)
+		}
+	case info.Mode().IsRegular():
+		err = c.copyFile(src, dst, info)
+	case info.Mode()&os.ModeSymlink != 0:
+		err = c.copySymlink(src, dst)
+	default:
+		err = c.copySpecial(dst, info)
+	}
+	if err == nil {
+		err = c.applyMeta(src, dst, info)
+	}
+	return c.fail(src, err)
+}
+
+// 按照覆盖策略判断是否跳过已存在的目标
+func (c *treeCopier) skipExisting(dst string, info os.FileInfo) (bool, error) {
+	dstInfo, err := os.Lstat(dst)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	switch c.opts.Overwrite {
+	case OverwriteNever:
+		return true, nil
+	case OverwriteIfNewer:
+		return !info.ModTime().After(dstInfo.ModTime()), nil
+	}
+	return false, nil
+}
+
+func (c *treeCopier) copyFile(src, dst string, info os.FileInfo) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	// 目标是软链接时先删除, 避免写入链接指向的文件
+	if dstInfo, err := os.Lstat(dst); err == nil && dstInfo.Mode()&os.ModeSymlink != 0 {
+		if err = os.Remove(dst); err != nil {
+			return err
+		}
+	}
+	return copyFileContext(c.ctx, in, info, dst, c.tracker)
+}
+
+func (c *treeCopier) copySymlink(src, dst string) error {
+	if err := removeExisting(dst, false); err != nil {
+		return err
+	}
+	return CopySymLink(src, dst)
+}
+
+// 重新创建FIFO和设备文件
+func (c *treeCopier) copySpecial(dst string, info os.FileInfo) error {
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	if err = removeExisting(dst, false); err != nil {
+		return err
+	}
+	return makeSpecialFile(dst, hdr)
+}
+
+// 按选项设置属主、权限、扩展属性和时间, 顺序不能调整: chown会清除setuid位, 其他修改会更新时间
+func (c *treeCopier) applyMeta(src, dst string, info os.FileInfo) error {
+	isSymlink := info.Mode()&os.ModeSymlink != 0
+	if c.opts.PreserveOwner {
+		if uid, gid, ok := fileOwner(info); ok {
+			if err := os.Lchown(dst, uid, gid); err != nil {
+				return err
+			}
+		}
+	}
+	if c.opts.PreserveMode && !isSymlink {
+		if err := os.Chmod(dst, info.Mode()&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky)); err != nil {
+			return err
+		}
+	}
+	if c.preserveAnyXattr() && !isSymlink {
+		xattrs, err := readXattrs(src)
+		if err != nil {
+			return err
+		}
+		for name, value := range xattrs {
+			if !c.preserveXattr(name) {
+				continue
+			}
+			if err = setXattr(dst, name, value); err != nil && !isXattrUnsupported(err) {
+				return err
+			}
+		}
+	}
+	if c.opts.PreserveTimes {
+		return setFileTimes(dst, fileAccessTime(info), info.ModTime())
+	}
+	return nil
+}
+
+func (c *treeCopier) preserveAnyXattr() bool {
+	return c.opts.PreserveXattrs || c.opts.PreserveACL || c.opts.PreserveSELinux || c.opts.PreserveCapabilities
+}
+
+// 按选项判断是否拷贝该扩展属性
+func (c *treeCopier) preserveXattr(name string) bool {
+	switch {
+	case c.opts.PreserveXattrs:
+		return true
+	case name == XattrACLAccess || name == XattrACLDefault:
+		return c.opts.PreserveACL
+	case name == XattrSELinux:
+		return c.opts.PreserveSELinux
+	case name == XattrCapability:
+		return c.opts.PreserveCapabilities
+	}
+	return false
+}
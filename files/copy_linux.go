@@ -0,0 +1,168 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// copy_file_range每次拷贝的最大字节数, 两次调用之间检查ctx并回调进度
+const copyRangeChunkSize = 8 * 1024 * 1024
+
+/*
+拷贝文件数据, 依次尝试以下方式, 不支持时自动回退
+1. FICLONE: btrfs、xfs等文件系统上以reflink共享数据块, 不实际拷贝数据
+2. SEEK_DATA/SEEK_HOLE: 只拷贝有数据的区域, 保留稀疏文件的空洞
+3. copy_file_range: 数据在内核中拷贝, 不经过用户态; 跨文件系统等不支持时使用pread/pwrite
+dst为普通文件时必须是新建的空文件; dst为字符设备、管道等时按顺序写入, 如 /dev/null
+/proc、/sys下的伪文件大小为0或与实际内容不符, 大小为0时按顺序读取; 实际内容较短时以读到的长度为准
+*/
+func copyFileData(ctx context.Context, dst, src *os.File, onWrite func(n int64)) (int64, error) {
+	info, err := src.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if !info.Mode().IsRegular() {
+		return copyContext(ctx, dst, src, onWrite)
+	}
+	// 只有普通文件支持按偏移写入和截断
+	if dstInfo, err := dst.Stat(); err != nil {
+		return 0, err
+	} else if !dstInfo.Mode().IsRegular() {
+		return copyContext(ctx, dst, src, onWrite)
+	}
+	size := info.Size()
+	if size == 0 {
+		return copyContext(ctx, dst, src, onWrite)
+	}
+	if unix.IoctlFileClone(int(dst.Fd()), int(src.Fd())) == nil {
+		if onWrite != nil {
+			onWrite(size)
+		}
+		return size, nil
+	}
+
+	c := &rangeCopier{ctx: ctx, dst: dst, src: src, onWrite: onWrite, useCopyFileRange: true}
+	for offset := int64(0); offset < size; {
+		data, hole, err := nextDataRegion(int(src.Fd()), offset, size)
+		if err != nil {
+			return c.written, err
+		}
+		if data >= size {
+			break
+		}
+		if err = c.copyRange(data, hole-data); err != nil {
+			return c.written, err
+		}
+		if c.eof {
+			// 源文件比记录的大小短, 如拷贝过程中被截断或者是伪文件
+			size = c.end
+			break
+		}
+		offset = hole
+	}
+	// 末尾的空洞没有写入数据, 通过截断设置文件大小
+	if err = dst.Truncate(size); err != nil {
+		return c.written, err
+	}
+	return c.written, nil
+}
+
+// 查找offset之后的数据区域[data, hole), 文件系统不支持SEEK_DATA时整个剩余部分视为数据
+func nextDataRegion(fd int, offset, size int64) (data, hole int64, err error) {
+	data, err = unix.Seek(fd, offset, unix.SEEK_DATA)
+	if errors.Is(err, unix.ENXIO) {
+		// offset之后只有空洞
+		return size, size, nil
+	} else if err != nil {
+		return offset, size, nil
+	}
+	hole, err = unix.Seek(fd, data, unix.SEEK_HOLE)
+	if err != nil || hole > size {
+		hole = size
+	}
+	return data, hole, nil
+}
+
+type rangeCopier struct {
+	ctx              context.Context
+	dst              *os.File
+	src              *os.File
+	onWrite          func(n int64)
+	written          int64
+	end              int64 // 已拷贝到的位置
+	eof              bool  // 在预期的长度之前读到了文件末尾
+	useCopyFileRange bool
+	buf              []byte
+}
+
+// 拷贝源文件[offset, offset+length)到目标文件的相同位置
+func (c *rangeCopier) copyRange(offset, length int64) error {
+	for length > 0 {
+		if err := c.ctx.Err(); err != nil {
+			return err
+		}
+		n, err := c.copyChunk(offset, length)
+		if n > 0 {
+			c.written += n
+			offset += n
+			length -= n
+			if c.onWrite != nil {
+				c.onWrite(n)
+			}
+		}
+		c.end = offset
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			c.eof = true
+			return nil
+		}
+	}
+	return nil
+}
+
+func (c *rangeCopier) copyChunk(offset, length int64) (int64, error) {
+	chunk := length
+	if chunk > copyRangeChunkSize {
+		chunk = copyRangeChunkSize
+	}
+	if c.useCopyFileRange {
+		roff, woff := offset, offset
+		n, err := unix.CopyFileRange(int(c.src.Fd()), &roff, int(c.dst.Fd()), &woff, int(chunk), 0)
+		if err == nil && n > 0 {
+			return int64(n), nil
+		}
+		if err != nil && !isCopyFileRangeUnsupported(err) {
+			return 0, err
+		}
+		// 部分伪文件不支持copy_file_range, 会直接返回0; 由pread确认是否真的到了文件末尾
+		c.useCopyFileRange = false
+	}
+
+	if c.buf == nil {
+		c.buf = make([]byte, copyBufferSize)
+	}
+	if chunk > int64(len(c.buf)) {
+		chunk = int64(len(c.buf))
+	}
+	nr, err := c.src.ReadAt(c.buf[:chunk], offset)
+	if nr == 0 {
+		if err == io.EOF {
+			err = nil
+		}
+		return 0, err
+	}
+	nw, err := c.dst.WriteAt(c.buf[:nr], offset)
+	return int64(nw), err
+}
+
+// 内核不支持, 或源和目标不在同一文件系统(旧内核)等情况, 需要回退到用户态拷贝
+func isCopyFileRangeUnsupported(err error) bool {
+	return errors.Is(err, unix.ENOSYS) || errors.Is(err, unix.EXDEV) || errors.Is(err, unix.EOPNOTSUPP) ||
+		errors.Is(err, unix.EINVAL) || errors.Is(err, unix.EPERM)
+}
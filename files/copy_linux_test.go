@@ -0,0 +1,203 @@
+package files
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// 构造稀疏文件: 每隔holeSize写入一块dataSize大小的数据, 末尾保留一个空洞
+func writeSparseFile(tb testing.TB, path string, blocks int, dataSize, holeSize int64) int64 {
+	tb.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer f.Close()
+	data := bytes.Repeat([]byte("glue"), int(dataSize/4))
+	var offset int64
+	for i := 0; i < blocks; i++ {
+		offset += holeSize
+		if _, err := f.WriteAt(data, offset); err != nil {
+			tb.Fatal(err)
+		}
+		offset += dataSize
+	}
+	size := offset + holeSize
+	if err := f.Truncate(size); err != nil {
+		tb.Fatal(err)
+	}
+	return size
+}
+
+func writeDenseFile(tb testing.TB, path string, size int) {
+	tb.Helper()
+	if err := os.WriteFile(path, bytes.Repeat([]byte{0x5a}, size), 0644); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// 文件实际占用的磁盘空间
+func allocatedSize(tb testing.TB, path string) int64 {
+	tb.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return info.Sys().(*syscall.Stat_t).Blocks * 512
+}
+
+func TestCopySparseFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+	size := writeSparseFile(t, src, 4, 64*1024, 4*1024*1024)
+	if allocatedSize(t, src) >= size {
+		t.Skip("当前文件系统不支持稀疏文件")
+	}
+
+	if err := Copy(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatal("拷贝后的内容与源文件不一致")
+	}
+	if allocated := allocatedSize(t, dst); allocated >= size {
+		t.Errorf("空洞未保留: 文件大小 %d 占用空间 %d", size, allocated)
+	}
+}
+
+// 目标不是普通文件时按顺序写入, 不能截断或按偏移写入
+func TestCopyToNonRegularFile(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "src")
+	writeDenseFile(t, src, 64*1024)
+	if err := Copy(src, os.DevNull); err != nil {
+		t.Fatalf("拷贝到%s失败: %v", os.DevNull, err)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	received := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		received <- data
+	}()
+	in, err := os.Open(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	n, err := copyFileData(context.Background(), w, in, nil)
+	w.Close()
+	if err != nil {
+		t.Fatalf("拷贝到管道失败: %v", err)
+	}
+	if data := <-received; n != 64*1024 || len(data) != 64*1024 {
+		t.Errorf("拷贝到管道的字节数 = %d, 读取到 %d, want %d", n, len(data), 64*1024)
+	}
+}
+
+// /proc下的文件大小为0, /sys下的文件大小为4096, 都与实际内容不符
+func TestCopyPseudoFile(t *testing.T) {
+	dir := t.TempDir()
+	for _, src := range []string{"/proc/self/cmdline", "/sys/devices/system/cpu/online"} {
+		want, err := os.ReadFile(src)
+		if err != nil {
+			t.Logf("跳过 %s: %v", src, err)
+			continue
+		}
+		dst := filepath.Join(dir, filepath.Base(src))
+		if err = Copy(src, dst); err != nil {
+			t.Fatalf("Copy(%s) error = %v", src, err)
+		}
+		got, err := os.ReadFile(dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(want) == 0 || !bytes.Equal(got, want) {
+			t.Errorf("Copy(%s) 拷贝了%d字节, want %d字节 %q", src, len(got), len(want), want)
+		}
+	}
+
+	// status中的内存等字段每次读取都可能变化, 只检查内容
+	dst := filepath.Join(dir, "status")
+	if err := Copy("/proc/self/status", dst); err != nil {
+		t.Fatalf("Copy(/proc/self/status) error = %v", err)
+	}
+	if got, err := os.ReadFile(dst); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Contains(got, []byte("Pid:")) {
+		t.Errorf("Copy(/proc/self/status) = %q, want 包含Pid:", got)
+	}
+}
+
+func benchmarkCopy(b *testing.B, src string, size int64, copyFunc func(dst, src *os.File) error) {
+	dst := filepath.Join(filepath.Dir(src), "dst")
+	b.SetBytes(size)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		in, err := os.Open(src)
+		if err != nil {
+			b.Fatal(err)
+		}
+		out, err := os.Create(dst)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err = copyFunc(out, in); err != nil {
+			b.Fatal(err)
+		}
+		in.Close()
+		out.Close()
+	}
+}
+
+// 原先经过用户态缓冲区的拷贝方式, 作为对比基准
+func userspaceCopy(dst, src *os.File) error {
+	_, err := copyContext(context.Background(), dst, src, nil)
+	return err
+}
+
+func fastCopy(dst, src *os.File) error {
+	_, err := copyFileData(context.Background(), dst, src, nil)
+	return err
+}
+
+func BenchmarkCopyDenseUserspace(b *testing.B) {
+	src := filepath.Join(b.TempDir(), "src")
+	writeDenseFile(b, src, 64*1024*1024)
+	benchmarkCopy(b, src, 64*1024*1024, userspaceCopy)
+}
+
+func BenchmarkCopyDenseFast(b *testing.B) {
+	src := filepath.Join(b.TempDir(), "src")
+	writeDenseFile(b, src, 64*1024*1024)
+	benchmarkCopy(b, src, 64*1024*1024, fastCopy)
+}
+
+func BenchmarkCopySparseUserspace(b *testing.B) {
+	src := filepath.Join(b.TempDir(), "src")
+	size := writeSparseFile(b, src, 8, 1024*1024, 64*1024*1024)
+	benchmarkCopy(b, src, size, userspaceCopy)
+}
+
+func BenchmarkCopySparseFast(b *testing.B) {
+	src := filepath.Join(b.TempDir(), "src")
+	size := writeSparseFile(b, src, 8, 1024*1024, 64*1024*1024)
+	benchmarkCopy(b, src, size, fastCopy)
+}
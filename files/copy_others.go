@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package files
+
+import (
+	"context"
+	"os"
+)
+
+func copyFileData(ctx context.Context, dst, src *os.File, onWrite func(n int64)) (int64, error) {
+	return copyContext(ctx, dst, src, onWrite)
+}
@@ -0,0 +1,137 @@
+package files
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCopyDirectoryWithOptions(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	writeTree(t, src, map[string]string{
+		"file.txt":     "new",
+		"sub/a.yml":    "a",
+		"logs/app.log": "log",
+	})
+	mustDo(t, os.Symlink("file.txt", filepath.Join(src, "link")))
+	mustDo(t, os.Symlink("sub", filepath.Join(src, "dirlink")))
+
+	tests := []struct {
+		name      string
+		opts      CopyOptions
+		existing  map[string]string
+		want      map[string]string
+		wantLinks []string
+	}{
+		{"复制软链接本身", CopyOptions{Exclude: []string{"logs/"}}, nil,
+			map[string]string{"file.txt": "new", "sub/a.yml": "a"}, []string{"dirlink", "link"}},
+		{"跟随软链接", CopyOptions{Symlinks: SymlinkFollow, Include: []string{"*.yml", "link"}}, nil,
+			map[string]string{"link": "new", "sub/a.yml": "a", "dirlink/a.yml": "a"}, nil},
+		{"跳过软链接", CopyOptions{Symlinks: SymlinkSkip, Exclude: []string{"*.log"}}, nil,
+			map[string]string{"file.txt": "new", "sub/a.yml": "a"}, nil},
+		{"从不覆盖", CopyOptions{Symlinks: SymlinkSkip, Overwrite: OverwriteNever, Include: []string{"file.txt"}}, map[string]string{"file.txt": "old"},
+			map[string]string{"file.txt": "old"}, nil},
+		{"源文件更新时覆盖", CopyOptions{Symlinks: SymlinkSkip, Overwrite: OverwriteIfNewer, Include: []string{"file.txt"}}, map[string]string{"file.txt": "old"},
+			map[string]string{"file.txt": "new"}, nil},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := filepath.Join(dir, "dst", string(rune('a'+i)))
+			if tt.existing != nil {
+				writeTree(t, dst, tt.existing)
+				old := time.Now().Add(-time.Hour)
+				for name := range tt.existing {
+					mustDo(t, os.Chtimes(filepath.Join(dst, name), old, old))
+				}
+			}
+			if err := CopyDirectoryWithOptions(context.Background(), src, dst, tt.opts); err != nil {
+				t.Fatalf("CopyDirectoryWithOptions() error = %v", err)
+			}
+			if got := readTree(t, dst); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CopyDirectoryWithOptions() = %v, want %v", got, tt.want)
+			}
+			var links []string
+			entries, err := os.ReadDir(dst)
+			mustDo(t, err)
+			for _, entry := range entries {
+				if entry.Type()&os.ModeSymlink != 0 {
+					links = append(links, entry.Name())
+				}
+			}
+			if !reflect.DeepEqual(links, tt.wantLinks) {
+				t.Errorf("目标中的软链接 = %v, want %v", links, tt.wantLinks)
+			}
+		})
+	}
+}
+
+// 进度的总字节数与实际拷贝的字节数一致, 不计入复制为链接的软链接和被排除的文件
+func TestCopyDirectoryProgress(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	writeTree(t, src, map[string]string{"keep.txt": "abc", "logs/app.log": "0123456789"})
+	writeTree(t, dir, map[string]string{"big.bin": string(make([]byte, 1000))})
+	mustDo(t, os.Symlink("keep.txt", filepath.Join(src, "link")))
+	mustDo(t, os.Symlink("../big.bin", filepath.Join(src, "big")))
+
+	tests := []struct {
+		name     string
+		symlinks SymlinkPolicy
+		want     int64
+	}{
+		{"复制软链接本身", SymlinkCopy, 3},
+		{"跟随软链接", SymlinkFollow, 1006},
+		{"跳过软链接", SymlinkSkip, 3},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var last Progress
+			opts := CopyOptions{Symlinks: tt.symlinks, Exclude: []string{"logs/"}, Progress: func(p Progress) { last = p }}
+			dst := filepath.Join(dir, "dst", string(rune('a'+i)))
+			if err := CopyDirectoryWithOptions(context.Background(), src, dst, opts); err != nil {
+				t.Fatalf("CopyDirectoryWithOptions() error = %v", err)
+			}
+			if last.Done != tt.want || last.Total != tt.want {
+				t.Errorf("最后一次进度 = %+v, want Done = Total = %d", last, tt.want)
+			}
+		})
+	}
+}
+
+func TestCopyDirectoryPreserve(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+	writeTree(t, src, map[string]string{"bin/run.sh": "#!/bin/sh\n"})
+	script := filepath.Join(src, "bin", "run.sh")
+	mustDo(t, os.Chmod(script, 0750|os.ModeSetgid))
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	mustDo(t, os.Chtimes(script, mtime, mtime))
+	mustDo(t, os.Chtimes(filepath.Join(src, "bin"), mtime, mtime))
+
+	var last Progress
+	opts := CopyOptions{PreserveMode: true, PreserveTimes: true, Progress: func(p Progress) { last = p }}
+	if err := CopyDirectoryWithOptions(context.Background(), src, dst, opts); err != nil {
+		t.Fatalf("CopyDirectoryWithOptions() error = %v", err)
+	}
+	for _, name := range []string{"bin", "bin/run.sh"} {
+		srcInfo, err := os.Stat(filepath.Join(src, name))
+		mustDo(t, err)
+		dstInfo, err := os.Stat(filepath.Join(dst, name))
+		mustDo(t, err)
+		if dstInfo.Mode() != srcInfo.Mode() || !dstInfo.ModTime().Equal(mtime) {
+			t.Errorf("%s = %v %v, want %v %v", name, dstInfo.Mode(), dstInfo.ModTime(), srcInfo.Mode(), mtime)
+		}
+	}
+	if last.Done != 10 || last.Total != 10 {
+		t.Errorf("最后一次进度 = %+v, want Done = Total = 10", last)
+	}
+
+	if err := CopyDirectoryWithOptions(context.Background(), script, dst, CopyOptions{}); err == nil {
+		t.Errorf("CopyDirectoryWithOptions(文件) error = nil, want error")
+	}
+}
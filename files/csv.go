@@ -0,0 +1,747 @@
+package files
+
+import (
+	"bufio"
+	"encoding"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/transform"
+)
+
+// 文本编码
+type TextEncoding string
+
+const (
+	EncodingUTF8    TextEncoding = "utf-8"
+	EncodingGBK     TextEncoding = "gbk"
+	EncodingGB18030 TextEncoding = "gb18030"
+)
+
+var (
+	ErrCSVQuote        = errors.New("引号后出现了多余的字符")
+	ErrCSVUnclosed     = errors.New("引号未闭合")
+	ErrCSVFieldCount   = errors.New("字段数量与表头不一致")
+	ErrCSVInvalidValue = errors.New("不支持的字段类型")
+)
+
+// CSV读写选项
+type CSVOptions struct {
+	// 字段分隔符, 默认','
+	Comma rune
+	// 引号, 默认'"'
+	Quote rune
+	// 注释符, 以该字符开头的行会被忽略, 0表示不启用; 仅读取
+	Comment rune
+	// 文件编码, 默认UTF-8; 读取时会跳过BOM
+	Encoding TextEncoding
+	// 写入UTF-8 BOM, 便于Excel识别编码, 只能用于UTF-8编码, 追加到非空文件时不会写入; 仅写入
+	WriteBOM bool
+	// 追加到已有文件末尾而不是清空; 仅OpenCSVWriter
+	Append bool
+	// 使用\r\n作为换行符; 仅写入
+	UseCRLF bool
+	// 单行解析或类型转换失败时的回调, 返回nil跳过该行继续读取, 返回错误则停止; 为nil时遇到错误即停止; 仅ReadCSVFile和DecodeCSVFile
+	OnRowError func(err *CSVRowError) error
+}
+
+func (o CSVOptions) withDefaults() CSVOptions {
+	if o.Comma == 0 {
+		o.Comma = ','
+	}
+	if o.Quote == 0 {
+		o.Quote = '"'
+	}
+	return o
+}
+
+// 单行解析或类型转换失败的错误
+type CSVRowError struct {
+	Line   int    // 该行记录在文件中的起始行号, 从1开始
+	Column string // 类型转换失败的列名, 解析失败时为空
+	Err    error
+}
+
+func (e *CSVRowError) Error() string {
+	if e.Column != "" {
+		return fmt.Sprintf("CSV第%d行 列%s: %v", e.Line, e.Column, e.Err)
+	}
+	return fmt.Sprintf("CSV第%d行: %v", e.Line, e.Err)
+}
+
+func (e *CSVRowError) Unwrap() error {
+	return e.Err
+}
+
+// 按编码解码, 输出UTF-8
+func newDecodingReader(r io.Reader, enc TextEncoding) (io.Reader, error) {
+	if isUTF8Encoding(enc) {
+		return r, nil
+	}
+	switch strings.ToLower(string(enc)) {
+	case string(EncodingGBK):
+		return transform.NewReader(r, simplifiedchinese.GBK.NewDecoder()), nil
+	case string(EncodingGB18030):
+		return transform.NewReader(r, simplifiedchinese.GB18030.NewDecoder()), nil
+	}
+	return nil, fmt.Errorf("不支持的编码: %s", enc)
+}
+
+func isUTF8Encoding(enc TextEncoding) bool {
+	switch strings.ToLower(string(enc)) {
+	case "", string(EncodingUTF8), "utf8":
+		return true
+	}
+	return false
+}
+
+// 写入选项的检查, BOM经过GBK等编码转换后会变成乱码
+func (o CSVOptions) checkWrite() error {
+	if o.WriteBOM && !isUTF8Encoding(o.Encoding) {
+		return fmt.Errorf("BOM只能用于UTF-8编码, 当前编码: %s", o.Encoding)
+	}
+	return nil
+}
+
+// 将UTF-8按编码转换后写入w
+func newEncodingWriter(w io.Writer, enc TextEncoding) (io.Writer, error) {
+	if isUTF8Encoding(enc) {
+		return w, nil
+	}
+	switch strings.ToLower(string(enc)) {
+	case string(EncodingGBK):
+		return transform.NewWriter(w, simplifiedchinese.GBK.NewEncoder()), nil
+	case string(EncodingGB18030):
+		return transform.NewWriter(w, simplifiedchinese.GB18030.NewEncoder()), nil
+	}
+	return nil, fmt.Errorf("不支持的编码: %s", enc)
+}
+
+/*
+流式CSV读取
+1. 支持自定义分隔符、引号和注释符, 空行会被跳过
+2. 引号内的字段可以包含分隔符、换行符, 两个连续的引号表示一个引号字符
+3. 某一行解析失败时返回*CSVRowError, 并跳过该行剩余的内容, 可以继续调用Read读取后面的行
+*/
+type CSVReader struct {
+	r       *bufio.Reader
+	opts    CSVOptions
+	err     error
+	line    int // 当前读取位置所在的行号
+	start   int // 上一条记录的起始行号
+	bomDone bool
+	header  []string
+	columns map[reflect.Type][]int
+}
+
+func NewCSVReader(r io.Reader, opts CSVOptions) *CSVReader {
+	cr := &CSVReader{opts: opts.withDefaults(), line: 1}
+	decoded, err := newDecodingReader(r, opts.Encoding)
+	if err != nil {
+		cr.err = err
+		decoded = r
+	}
+	cr.r = bufio.NewReader(decoded)
+	return cr
+}
+
+// 上一条记录的起始行号
+func (r *CSVReader) Line() int {
+	return r.start
+}
+
+// 读取一条记录, 读完时返回io.EOF
+func (r *CSVReader) Read() ([]string, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if !r.bomDone {
+		r.bomDone = true
+		if c, _, err := r.r.ReadRune(); err == nil && c != '\uFEFF' {
+			r.r.UnreadRune()
+		}
+	}
+	if err := r.skipEmptyAndComments(); err != nil {
+		return nil, err
+	}
+	r.start = r.line
+	record, err := r.readRecord()
+	if err != nil && err != io.EOF {
+		r.skipLine()
+		return nil, &CSVRowError{Line: r.start, Err: err}
+	}
+	return record, nil
+}
+
+// 跳过空行和注释行
+func (r *CSVReader) skipEmptyAndComments() error {
+	for {
+		c, _, err := r.r.ReadRune()
+		if err != nil {
+			return err
+		}
+		switch {
+		case c == '\n':
+			r.line++
+		case c == '\r':
+			if next, _, err := r.r.ReadRune(); err == nil && next != '\n' {
+				r.r.UnreadRune()
+			}
+			r.line++
+		case r.opts.Comment != 0 && c == r.opts.Comment:
+			r.skipLine()
+		default:
+			return r.r.UnreadRune()
+		}
+	}
+}
+
+// 跳过当前行剩余的内容
+func (r *CSVReader) skipLine() {
+	for {
+		c, _, err := r.r.ReadRune()
+		if err != nil {
+			return
+		}
+		if c == '\n' {
+			r.line++
+			return
+		}
+	}
+}
+
+// 读取一个换行符, \r\n视为一个换行
+func (r *CSVReader) isNewline(c rune) bool {
+	if c == '\r' {
+		if next, _, err := r.r.ReadRune(); err == nil && next != '\n' {
+			r.r.UnreadRune()
+		}
+		return true
+	}
+	return c == '\n'
+}
+
+func (r *CSVReader) readRecord() ([]string, error) {
+	var record []string
+	var field strings.Builder
+	for {
+		field.Reset()
+		c, _, err := r.r.ReadRune()
+		if err == nil && c == r.opts.Quote {
+			endOfRecord, err := r.readQuoted(&field)
+			record = append(record, field.String())
+			if err != nil || endOfRecord {
+				return record, err
+			}
+			continue
+		}
+		// 不带引号的字段, 读到分隔符或换行为止
+		for {
+			if err == io.EOF {
+				return append(record, field.String()), nil
+			} else if err != nil {
+				return record, err
+			}
+			if c == r.opts.Comma {
+				break
+			}
+			if r.isNewline(c) {
+				r.line++
+				return append(record, field.String()), nil
+			}
+			field.WriteRune(c)
+			c, _, err = r.r.ReadRune()
+		}
+		record = append(record, field.String())
+	}
+}
+
+// 读取引号内的字段, 返回该字段是否为记录的最后一个字段
+func (r *CSVReader) readQuoted(field *strings.Builder) (bool, error) {
+	for {
+		c, _, err := r.r.ReadRune()
+		if err == io.EOF {
+			return true, ErrCSVUnclosed
+		} else if err != nil {
+			return true, err
+		}
+		if c != r.opts.Quote {
+			if c == '\r' {
+				if next, _, err := r.r.ReadRune(); err == nil && next != '\n' {
+					r.r.UnreadRune()
+				}
+				c = '\n'
+			}
+			if c == '\n' {
+				r.line++
+			}
+			field.WriteRune(c)
+			continue
+		}
+		c, _, err = r.r.ReadRune()
+		switch {
+		case err == io.EOF:
+			return true, nil
+		case err != nil:
+			return true, err
+		case c == r.opts.Quote:
+			field.WriteRune(c)
+		case c == r.opts.Comma:
+			return false, nil
+		case r.isNewline(c):
+			r.line++
+			return true, nil
+		default:
+			r.r.UnreadRune()
+			return true, ErrCSVQuote
+		}
+	}
+}
+
+// 读取表头, 必须在读取数据之前调用; 使用Decode时会自动读取
+func (r *CSVReader) ReadHeader() ([]string, error) {
+	header, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+	r.header = header
+	r.columns = map[reflect.Type][]int{}
+	return header, nil
+}
+
+/*
+读取一条记录并按表头映射到结构体, v必须是结构体指针
+1. 字段通过csv标签指定列名, 没有标签时使用字段名, 标签为-时忽略
+2. 支持字符串、整数、浮点数、布尔、time.Duration以及实现了encoding.TextUnmarshaler的类型(如time.Time, 格式为RFC3339), 以及它们的指针
+3. 空字符串转换为零值, 指针类型保持nil
+4. 表头中不存在的字段保持原值, 结构体中不存在的列被忽略
+*/
+func (r *CSVReader) Decode(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Decode的参数必须是结构体指针, 实际为: %T", v)
+	}
+	if r.header == nil {
+		if _, err := r.ReadHeader(); err != nil {
+			return err
+		}
+	}
+	record, err := r.Read()
+	if err != nil {
+		return err
+	}
+	if len(record) != len(r.header) {
+		return &CSVRowError{Line: r.start, Err: fmt.Errorf("%w: 表头%d列, 该行%d列", ErrCSVFieldCount, len(r.header), len(record))}
+	}
+	rv = rv.Elem()
+	columns := r.structColumns(rv.Type())
+	fields := csvStructFields(rv.Type())
+	for i, col := range columns {
+		if col < 0 {
+			continue
+		}
+		if err := setCSVValue(rv.Field(fields[i].index), record[col]); err != nil {
+			return &CSVRowError{Line: r.start, Column: r.header[col], Err: err}
+		}
+	}
+	return nil
+}
+
+// 结构体字段对应的列序号, 不存在时为-1
+func (r *CSVReader) structColumns(t reflect.Type) []int {
+	if columns, ok := r.columns[t]; ok {
+		return columns
+	}
+	index := make(map[string]int, len(r.header))
+	for i, name := range r.header {
+		if _, exists := index[name]; !exists {
+			index[name] = i
+		}
+	}
+	fields := csvStructFields(t)
+	columns := make([]int, len(fields))
+	for i, field := range fields {
+		col, ok := index[field.name]
+		if !ok {
+			col = -1
+		}
+		columns[i] = col
+	}
+	r.columns[t] = columns
+	return columns
+}
+
+type csvField struct {
+	name  string
+	index int
+}
+
+var csvFieldCache sync.Map
+
+// 结构体中参与CSV读写的字段
+func csvStructFields(t reflect.Type) []csvField {
+	if cached, ok := csvFieldCache.Load(t); ok {
+		return cached.([]csvField)
+	}
+	var fields []csvField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := f.Tag.Get("csv")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, csvField{name: name, index: i})
+	}
+	csvFieldCache.Store(t, fields)
+	return fields
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func setCSVValue(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Ptr {
+		if s == "" {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setCSVValue(v.Elem(), s)
+	}
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		if s == "" {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+		return u.UnmarshalText([]byte(s))
+	}
+	if v.Kind() != reflect.String {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Type() == durationType {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return err
+			}
+			v.SetInt(int64(d))
+			return nil
+		}
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("%w: %s", ErrCSVInvalidValue, v.Type())
+	}
+	return nil
+}
+
+func formatCSVValue(v reflect.Value) (string, error) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		text, err := m.MarshalText()
+		return string(text), err
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Type() == durationType {
+			return time.Duration(v.Int()).String(), nil
+		}
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits()), nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrCSVInvalidValue, v.Type())
+}
+
+// 流式CSV写入, 写入完成后需要调用Flush或Close
+type CSVWriter struct {
+	w        *bufio.Writer
+	closer   io.Closer
+	opts     CSVOptions
+	err      error
+	appended bool // 追加到了非空文件
+}
+
+func NewCSVWriter(w io.Writer, opts CSVOptions) *CSVWriter {
+	cw := &CSVWriter{opts: opts.withDefaults()}
+	encoded, err := newEncodingWriter(w, opts.Encoding)
+	if err == nil {
+		err = opts.checkWrite()
+	}
+	if err != nil {
+		cw.err = err
+		encoded = w
+	}
+	cw.w = bufio.NewWriter(encoded)
+	if opts.WriteBOM && cw.err == nil {
+		cw.w.WriteString("\uFEFF")
+	}
+	return cw
+}
+
+// 打开CSV文件用于写入, Append为true时追加到文件末尾, 此时WriteHeader在文件非空时不会重复写入表头
+func OpenCSVWriter(csvFile string, opts CSVOptions) (*CSVWriter, error) {
+	// 在打开文件之前检查, 避免清空已有的文件
+	if err := opts.checkWrite(); err != nil {
+		return nil, err
+	}
+	flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if opts.Append {
+		flag = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	f, err := os.OpenFile(csvFile, flag, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	appended := opts.Append && info.Size() > 0
+	if appended {
+		opts.WriteBOM = false
+	}
+	cw := NewCSVWriter(f, opts)
+	if cw.err != nil {
+		f.Close()
+		return nil, cw.err
+	}
+	cw.closer = f
+	cw.appended = appended
+	return cw, nil
+}
+
+// 写入一条记录
+func (w *CSVWriter) Write(record []string) error {
+	if w.err != nil {
+		return w.err
+	}
+	for i, field := range record {
+		if i > 0 {
+			w.w.WriteRune(w.opts.Comma)
+		}
+		if !w.needsQuotes(field) {
+			w.w.WriteString(field)
+			continue
+		}
+		w.w.WriteRune(w.opts.Quote)
+		for _, c := range field {
+			if c == w.opts.Quote {
+				w.w.WriteRune(c)
+			}
+			if c == '\n' && w.opts.UseCRLF {
+				w.w.WriteRune('\r')
+			}
+			w.w.WriteRune(c)
+		}
+		w.w.WriteRune(w.opts.Quote)
+	}
+	if w.opts.UseCRLF {
+		w.w.WriteRune('\r')
+	}
+	_, w.err = w.w.WriteRune('\n')
+	return w.err
+}
+
+func (w *CSVWriter) needsQuotes(field string) bool {
+	if field == "" {
+		return false
+	}
+	if strings.ContainsRune(field, w.opts.Comma) || strings.ContainsRune(field, w.opts.Quote) || strings.ContainsAny(field, "\r\n") {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(field)
+	return r == ' ' || r == '\t'
+}
+
+// 按结构体的csv标签写入表头, v为结构体或结构体指针
+func (w *CSVWriter) WriteHeader(v interface{}) error {
+	t := reflect.TypeOf(v)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return fmt.Errorf("WriteHeader的参数必须是结构体, 实际为: %T", v)
+	}
+	if w.appended {
+		return nil
+	}
+	fields := csvStructFields(t)
+	header := make([]string, len(fields))
+	for i, field := range fields {
+		header[i] = field.name
+	}
+	return w.Write(header)
+}
+
+// 按结构体字段顺序写入一条记录, v为结构体或结构体指针
+func (w *CSVWriter) Encode(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("Encode的参数必须是结构体, 实际为: %T", v)
+	}
+	fields := csvStructFields(rv.Type())
+	record := make([]string, len(fields))
+	for i, field := range fields {
+		s, err := formatCSVValue(rv.Field(field.index))
+		if err != nil {
+			return fmt.Errorf("字段%s: %w", field.name, err)
+		}
+		record[i] = s
+	}
+	return w.Write(record)
+}
+
+// 将缓冲区中的数据写入底层
+func (w *CSVWriter) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	w.err = w.w.Flush()
+	return w.err
+}
+
+// 写入剩余数据并关闭OpenCSVWriter打开的文件
+func (w *CSVWriter) Close() error {
+	err := w.Flush()
+	if w.closer != nil {
+		if closeErr := w.closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// 逐行读取CSV文件并回调, 第一个参数为该行的行号; 回调返回StopWalk时提前结束并返回nil
+func ReadCSVFile(csvFile string, opts CSVOptions, fn func(line int, record []string) error) error {
+	f, err := os.Open(csvFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := NewCSVReader(f, opts)
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err = handleCSVRowError(err, opts); err != nil {
+			return err
+		}
+		if record == nil {
+			continue
+		}
+		if err = fn(r.Line(), record); err == StopWalk {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// 读取带表头的CSV文件, 每行映射为T类型的结构体后回调; 回调返回StopWalk时提前结束并返回nil
+func DecodeCSVFile[T any](csvFile string, opts CSVOptions, fn func(line int, row *T) error) error {
+	f, err := os.Open(csvFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r := NewCSVReader(f, opts)
+	if _, err = r.ReadHeader(); err == io.EOF {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for {
+		row := new(T)
+		err := r.Decode(row)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if err = handleCSVRowError(err, opts); err != nil {
+				return err
+			}
+			continue
+		}
+		if err = fn(r.Line(), row); err == StopWalk {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// 按OnRowError处理单行错误, 返回nil时跳过该行
+func handleCSVRowError(err error, opts CSVOptions) error {
+	if err == nil {
+		return nil
+	}
+	var rowErr *CSVRowError
+	if !errors.As(err, &rowErr) || opts.OnRowError == nil {
+		return err
+	}
+	return opts.OnRowError(rowErr)
+}
@@ -0,0 +1,142 @@
+package files
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCSVReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		opts    CSVOptions
+		want    [][]string
+		wantErr error
+	}{
+		{"普通字段", "a,b,c\n1,2,3\n", CSVOptions{}, [][]string{{"a", "b", "c"}, {"1", "2", "3"}}, nil},
+		{"跳过BOM和空行", "\uFEFFa,b\r\n\r\n1,2", CSVOptions{}, [][]string{{"a", "b"}, {"1", "2"}}, nil},
+		{"引号内的分隔符和换行", "\"a,b\",\"x\"\"y\"\n\"1\r\n2\",3\n", CSVOptions{}, [][]string{{"a,b", "x\"y"}, {"1\n2", "3"}}, nil},
+		{"自定义分隔符和注释", "# 注释\na;b\n", CSVOptions{Comma: ';', Comment: '#'}, [][]string{{"a", "b"}}, nil},
+		{"引号未闭合", "a,\"b\n", CSVOptions{}, [][]string{{"a"}}, ErrCSVUnclosed},
+		{"引号后多余的字符", "\"a\"b,c\n1,2\n", CSVOptions{}, [][]string{{"1", "2"}}, ErrCSVQuote},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewCSVReader(strings.NewReader(tt.input), tt.opts)
+			var got [][]string
+			var gotErr error
+			for {
+				record, err := r.Read()
+				if err == io.EOF {
+					break
+				} else if err != nil {
+					gotErr = err
+					continue
+				}
+				got = append(got, record)
+			}
+			if !errors.Is(gotErr, tt.wantErr) || (gotErr != nil) != (tt.wantErr != nil) {
+				t.Fatalf("Read() error = %v, want %v", gotErr, tt.wantErr)
+			}
+			if tt.wantErr == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Read() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+type csvTestRow struct {
+	Name    string        `csv:"name"`
+	Port    int           `csv:"port"`
+	Timeout time.Duration `csv:"timeout"`
+	Weight  *float64      `csv:"weight"`
+	Ignored string        `csv:"-"`
+}
+
+func TestCSVEncodeDecode(t *testing.T) {
+	weight := 0.5
+	rows := []csvTestRow{
+		{Name: "节点1", Port: 80, Timeout: 3 * time.Second, Weight: &weight},
+		{Name: "a,\"b\"\nc", Port: 8080},
+	}
+	for _, enc := range []TextEncoding{EncodingUTF8, EncodingGBK, EncodingGB18030} {
+		t.Run(string(enc), func(t *testing.T) {
+			csvFile := filepath.Join(t.TempDir(), "rows.csv")
+			opts := CSVOptions{Encoding: enc, UseCRLF: true, WriteBOM: enc == EncodingUTF8}
+			w, err := OpenCSVWriter(csvFile, opts)
+			mustDo(t, err)
+			mustDo(t, w.WriteHeader(csvTestRow{}))
+			for _, row := range rows {
+				mustDo(t, w.Encode(row))
+			}
+			mustDo(t, w.Close())
+
+			var got []csvTestRow
+			err = DecodeCSVFile(csvFile, opts, func(line int, row *csvTestRow) error {
+				got = append(got, *row)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("DecodeCSVFile() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, rows) {
+				t.Errorf("DecodeCSVFile() = %+v, want %+v", got, rows)
+			}
+		})
+	}
+}
+
+func TestCSVWriterBOM(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewCSVWriter(&buf, CSVOptions{WriteBOM: true})
+	mustDo(t, w.Write([]string{"名称"}))
+	mustDo(t, w.Flush())
+	if want := "\uFEFF名称\n"; buf.String() != want {
+		t.Errorf("Write() = %q, want %q", buf.String(), want)
+	}
+
+	// BOM只能用于UTF-8编码, 检查失败时不能清空已有的文件
+	csvFile := filepath.Join(t.TempDir(), "gbk.csv")
+	mustDo(t, os.WriteFile(csvFile, []byte("old\n"), 0644))
+	for _, enc := range []TextEncoding{EncodingGBK, EncodingGB18030} {
+		if _, err := OpenCSVWriter(csvFile, CSVOptions{Encoding: enc, WriteBOM: true}); err == nil {
+			t.Errorf("OpenCSVWriter(%s, WriteBOM) error = nil, want error", enc)
+		}
+		buf.Reset()
+		if err := NewCSVWriter(&buf, CSVOptions{Encoding: enc, WriteBOM: true}).Write([]string{"a"}); err == nil || buf.Len() != 0 {
+			t.Errorf("NewCSVWriter(%s, WriteBOM).Write() error = %v, written %q", enc, err, buf.String())
+		}
+	}
+	if content, _ := os.ReadFile(csvFile); string(content) != "old\n" {
+		t.Errorf("文件内容 = %q, want %q", content, "old\n")
+	}
+}
+
+func TestCSVOnRowError(t *testing.T) {
+	csvFile := filepath.Join(t.TempDir(), "rows.csv")
+	mustDo(t, os.WriteFile(csvFile, []byte("name,port\na,1\nb,x\nc\nd,4\n"), 0644))
+	var names []string
+	var lines []int
+	opts := CSVOptions{OnRowError: func(err *CSVRowError) error {
+		lines = append(lines, err.Line)
+		return nil
+	}}
+	err := DecodeCSVFile(csvFile, opts, func(line int, row *csvTestRow) error {
+		names = append(names, row.Name)
+		return nil
+	})
+	mustDo(t, err)
+	if want := []string{"a", "d"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("DecodeCSVFile() = %v, want %v", names, want)
+	}
+	if want := []int{3, 4}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("出错的行号 = %v, want %v", lines, want)
+	}
+}
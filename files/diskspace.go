@@ -0,0 +1,79 @@
+package files
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+var (
+	ErrInsufficientSpace  = errors.New("磁盘剩余空间不足")
+	ErrInsufficientInodes = errors.New("磁盘剩余inode不足")
+)
+
+// 路径所在文件系统的空间信息
+type DiskSpace struct {
+	Path       string `json:"path"`
+	MountPoint string `json:"mount_point"`
+	FSType     string `json:"fs_type"`
+	Total      uint64 `json:"total"`       // 总字节数
+	Free       uint64 `json:"free"`        // 剩余字节数, 包括只有root可用的保留空间
+	Available  uint64 `json:"available"`   // 普通用户可用的字节数
+	Inodes     uint64 `json:"inodes"`      // inode总数, 部分文件系统(如btrfs)为0
+	InodesFree uint64 `json:"inodes_free"` // 剩余inode数
+}
+
+// 获取路径所在文件系统的空间信息, 路径不存在时使用最近的已存在的上级目录
+func GetDiskSpace(path string) (*DiskSpace, error) {
+	existing, err := nearestExistingPath(path)
+	if err != nil {
+		return nil, err
+	}
+	space, err := statDiskSpace(existing)
+	if err != nil {
+		return nil, err
+	}
+	space.Path = path
+	return space, nil
+}
+
+/*
+检查路径所在文件系统是否能写入needBytes字节和needInodes个文件
+1. 以普通用户可用的空间为准, 空间不足时返回ErrInsufficientSpace
+2. 不提供inode数量的文件系统不检查inode
+*/
+func CheckDiskSpace(path string, needBytes, needInodes int64) error {
+	space, err := GetDiskSpace(path)
+	if err != nil {
+		return err
+	}
+	if needBytes > 0 && uint64(needBytes) > space.Available {
+		return fmt.Errorf("%w: %s 需要%d字节, 可用%d字节", ErrInsufficientSpace, space.MountPoint, needBytes, space.Available)
+	}
+	if needInodes > 0 && space.Inodes > 0 && uint64(needInodes) > space.InodesFree {
+		return fmt.Errorf("%w: %s 需要%d个, 剩余%d个", ErrInsufficientInodes, space.MountPoint, needInodes, space.InodesFree)
+	}
+	return nil
+}
+
+// 向上查找最近的已存在的路径, 用于检查尚未创建的目标目录
+func nearestExistingPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	for {
+		_, err := os.Stat(abs)
+		if err == nil {
+			return abs, nil
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(abs)
+		if parent == abs {
+			return "", err
+		}
+		abs = parent
+	}
+}
@@ -0,0 +1,56 @@
+package files
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+func statDiskSpace(path string) (*DiskSpace, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return nil, &os.PathError{Op: "statfs", Path: path, Err: err}
+	}
+	// 文件系统类型以挂载表为准, 无法读取挂载表时为unknown
+	mountPoint, fsType := "", "unknown"
+	if mount, err := FindMount(path); err == nil {
+		mountPoint, fsType = mount.MountPoint, mount.FSType
+	} else if mountPoint, err = findMountPoint(path); err != nil {
+		return nil, err
+	}
+	bsize := uint64(st.Bsize)
+	return &DiskSpace{
+		MountPoint: mountPoint,
+		FSType:     fsType,
+		Total:      st.Blocks * bsize,
+		Free:       st.Bfree * bsize,
+		Available:  st.Bavail * bsize,
+		Inodes:     st.Files,
+		InodesFree: st.Ffree,
+	}, nil
+}
+
+// 无法读取挂载表时, 向上查找直到设备号变化, 得到路径所在的挂载点; 同一文件系统的bind挂载无法区分
+func findMountPoint(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	dev := info.Sys().(*syscall.Stat_t).Dev
+	for {
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path, nil
+		}
+		parentInfo, err := os.Stat(parent)
+		if err != nil {
+			return "", err
+		}
+		if parentInfo.Sys().(*syscall.Stat_t).Dev != dev {
+			return path, nil
+		}
+		path = parent
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package files
+
+func statDiskSpace(path string) (*DiskSpace, error) {
+	return nil, errMetaUnsupported
+}
@@ -0,0 +1,46 @@
+package files
+
+import (
+	"errors"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetDiskSpace(t *testing.T) {
+	dir := t.TempDir()
+	// 路径不存在时使用最近的已存在的上级目录
+	path := filepath.Join(dir, "not", "exist")
+	space, err := GetDiskSpace(path)
+	if err != nil {
+		t.Fatalf("GetDiskSpace() error = %v", err)
+	}
+	if space.Path != path {
+		t.Errorf("GetDiskSpace().Path = %s, want %s", space.Path, path)
+	}
+	if space.MountPoint == "" || space.FSType == "" || space.Total == 0 || space.Available > space.Free {
+		t.Errorf("GetDiskSpace() = %+v, 空间信息无效", space)
+	}
+}
+
+func TestCheckDiskSpace(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name       string
+		needBytes  int64
+		needInodes int64
+		wantErr    error
+	}{
+		{"空间足够", 1, 0, nil},
+		{"不检查", 0, 0, nil},
+		{"空间不足", math.MaxInt64, 0, ErrInsufficientSpace},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckDiskSpace(dir, tt.needBytes, tt.needInodes)
+			if !errors.Is(err, tt.wantErr) || (err != nil) != (tt.wantErr != nil) {
+				t.Errorf("CheckDiskSpace() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
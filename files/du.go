@@ -0,0 +1,198 @@
+package files
+
+import (
+	"container/heap"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// 磁盘占用统计的选项
+type DiskUsageOptions struct {
+	// 返回占用空间最大的N个文件和目录, 0表示不返回
+	TopN int
+	// 不统计挂载在其他文件系统上的子目录, 与du -x相同
+	OneFileSystem bool
+	// gitignore风格的排除规则, 相对于根目录匹配
+	Exclude []string
+}
+
+// 文件或目录的磁盘占用
+type DiskUsage struct {
+	Path          string `json:"path"`
+	ApparentSize  int64  `json:"apparent_size"`  // 文件大小之和
+	AllocatedSize int64  `json:"allocated_size"` // 实际占用的磁盘空间(st_blocks), 稀疏文件会小于ApparentSize
+	Files         int64  `json:"files"`          // 目录下(含子目录)非目录条目的数量
+	Dirs          int64  `json:"dirs"`           // 目录下(含子目录)的子目录数量
+}
+
+// 磁盘占用统计结果
+type DiskUsageReport struct {
+	DiskUsage
+	// 每个目录(含根目录)的小计, 键为目录路径
+	Subtotals map[string]DiskUsage `json:"subtotals"`
+	// 按AllocatedSize从大到小排列的文件
+	TopFiles []DiskUsage `json:"top_files,omitempty"`
+	// 按AllocatedSize从大到小排列的子目录, 不包括根目录
+	TopDirs []DiskUsage `json:"top_dirs,omitempty"`
+}
+
+/*
+统计目录的磁盘占用, 与du相同
+1. 软链接按链接本身统计, 不跟随
+2. 有多个硬链接的文件只在第一次遇到时统计
+3. 无法读取的目录会被跳过并继续统计, 返回结果和第一个错误
+*/
+func CalcDiskUsage(ctx context.Context, root string, opts DiskUsageOptions) (*DiskUsageReport, error) {
+	info, err := os.Lstat(root)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := NewPathMatcher(opts.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	c := &duCounter{
+		ctx:       ctx,
+		root:      root,
+		opts:      opts,
+		exclude:   exclude,
+		inodes:    map[[2]uint64]bool{},
+		subtotals: map[string]DiskUsage{},
+	}
+	if rootDev, _, _, ok := fileInode(info); ok {
+		c.rootDev = rootDev
+	}
+	report := &DiskUsageReport{Subtotals: c.subtotals}
+	if info.IsDir() {
+		report.DiskUsage = c.walkDir(root, info)
+	} else {
+		report.DiskUsage = c.fileUsage(root, info)
+		c.topFiles.push(report.DiskUsage, opts.TopN)
+	}
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	report.TopFiles = c.topFiles.sorted()
+	if opts.TopN > 0 {
+		var dirs duHeap
+		for path, usage := range c.subtotals {
+			if path != root {
+				dirs.push(usage, opts.TopN)
+			}
+		}
+		report.TopDirs = dirs.sorted()
+	}
+	return report, c.err
+}
+
+type duCounter struct {
+	ctx       context.Context
+	root      string
+	opts      DiskUsageOptions
+	exclude   *PathMatcher
+	rootDev   uint64
+	inodes    map[[2]uint64]bool
+	subtotals map[string]DiskUsage
+	topFiles  duHeap
+	err       error
+}
+
+func (c *duCounter) fileUsage(path string, info os.FileInfo) DiskUsage {
+	usage := DiskUsage{Path: path, Files: 1}
+	if dev, ino, nlink, ok := fileInode(info); ok && nlink > 1 {
+		key := [2]uint64{dev, ino}
+		if c.inodes[key] {
+			return usage
+		}
+		c.inodes[key] = true
+	}
+	usage.ApparentSize = info.Size()
+	usage.AllocatedSize = fileAllocatedSize(info)
+	return usage
+}
+
+func (c *duCounter) walkDir(dir string, info os.FileInfo) DiskUsage {
+	total := DiskUsage{Path: dir, ApparentSize: info.Size(), AllocatedSize: fileAllocatedSize(info)}
+	entries, err := os.ReadDir(dir)
+	if err != nil && c.err == nil {
+		c.err = err
+	}
+	for _, entry := range entries {
+		if c.ctx.Err() != nil {
+			break
+		}
+		path := filepath.Join(dir, entry.Name())
+		if rel, err := filepath.Rel(c.root, path); err == nil && c.exclude.Match(filepath.ToSlash(rel), entry.IsDir()) {
+			continue
+		}
+		entryInfo, err := entry.Info()
+		if err != nil {
+			if !os.IsNotExist(err) && c.err == nil {
+				c.err = err
+			}
+			continue
+		}
+		if entryInfo.IsDir() {
+			if dev, _, _, ok := fileInode(entryInfo); ok && c.opts.OneFileSystem && dev != c.rootDev {
+				continue
+			}
+			sub := c.walkDir(path, entryInfo)
+			total.ApparentSize += sub.ApparentSize
+			total.AllocatedSize += sub.AllocatedSize
+			total.Files += sub.Files
+			total.Dirs += sub.Dirs + 1
+			continue
+		}
+		usage := c.fileUsage(path, entryInfo)
+		c.topFiles.push(usage, c.opts.TopN)
+		total.ApparentSize += usage.ApparentSize
+		total.AllocatedSize += usage.AllocatedSize
+		total.Files++
+	}
+	c.subtotals[dir] = total
+	return total
+}
+
+// 按AllocatedSize保留最大的N项的小顶堆
+type duHeap []DiskUsage
+
+func (h duHeap) Len() int            { return len(h) }
+func (h duHeap) Less(i, j int) bool  { return h[i].AllocatedSize < h[j].AllocatedSize }
+func (h duHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *duHeap) Push(x interface{}) { *h = append(*h, x.(DiskUsage)) }
+func (h *duHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+func (h *duHeap) push(usage DiskUsage, n int) {
+	if n <= 0 {
+		return
+	}
+	if h.Len() < n {
+		heap.Push(h, usage)
+	} else if usage.AllocatedSize > (*h)[0].AllocatedSize {
+		(*h)[0] = usage
+		heap.Fix(h, 0)
+	}
+}
+
+// 从大到小排列
+func (h duHeap) sorted() []DiskUsage {
+	if len(h) == 0 {
+		return nil
+	}
+	items := append([]DiskUsage(nil), h...)
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].AllocatedSize != items[j].AllocatedSize {
+			return items[i].AllocatedSize > items[j].AllocatedSize
+		}
+		return items[i].Path < items[j].Path
+	})
+	return items
+}
@@ -0,0 +1,54 @@
+package files
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCalcDiskUsage(t *testing.T) {
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "sub")
+	writeTree(t, dir, map[string]string{
+		"big.bin": string(make([]byte, 64*1024)),
+		"sub/a":   string(make([]byte, 100)),
+		"x.tmp":   "excluded",
+	})
+	// 硬链接只统计一次
+	mustDo(t, os.Link(filepath.Join(sub, "a"), filepath.Join(sub, "hard")))
+	sparse := filepath.Join(dir, "sparse")
+	mustDo(t, os.WriteFile(sparse, nil, 0644))
+	mustDo(t, os.Truncate(sparse, 1024*1024))
+
+	report, err := CalcDiskUsage(context.Background(), dir, DiskUsageOptions{TopN: 2, Exclude: []string{"*.tmp"}})
+	if err != nil {
+		t.Fatalf("CalcDiskUsage() error = %v", err)
+	}
+	dirInfo, err := os.Stat(dir)
+	mustDo(t, err)
+	subInfo, err := os.Stat(sub)
+	mustDo(t, err)
+	if report.Files != 4 || report.Dirs != 1 {
+		t.Errorf("CalcDiskUsage() Files = %d, Dirs = %d, want 4, 1", report.Files, report.Dirs)
+	}
+	if want := dirInfo.Size() + subInfo.Size() + 64*1024 + 100 + 1024*1024; report.ApparentSize != want {
+		t.Errorf("CalcDiskUsage().ApparentSize = %d, want %d", report.ApparentSize, want)
+	}
+	if got := report.Subtotals[sub]; got.Files != 2 || got.ApparentSize != subInfo.Size()+100 {
+		t.Errorf("Subtotals[sub] = %+v, want Files 2, ApparentSize %d", got, subInfo.Size()+100)
+	}
+	if len(report.TopFiles) != 2 || report.TopFiles[0].Path != filepath.Join(dir, "big.bin") {
+		t.Errorf("CalcDiskUsage().TopFiles = %+v, want big.bin排在第一", report.TopFiles)
+	}
+	if len(report.TopDirs) != 1 || report.TopDirs[0].Path != sub {
+		t.Errorf("CalcDiskUsage().TopDirs = %+v, want [%s]", report.TopDirs, sub)
+	}
+
+	// 稀疏文件的实际占用小于文件大小
+	usage, err := CalcDiskUsage(context.Background(), sparse, DiskUsageOptions{})
+	mustDo(t, err)
+	if usage.ApparentSize != 1024*1024 || usage.AllocatedSize >= usage.ApparentSize {
+		t.Errorf("CalcDiskUsage(sparse) = %+v, want AllocatedSize < ApparentSize", usage.DiskUsage)
+	}
+}
@@ -0,0 +1,471 @@
+package files
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 安全解压时的错误类型, 可以使用errors.Is判断
+var (
+	ErrAbsolutePath     = errors.New("条目使用了绝对路径")
+	ErrPathTraversal    = errors.New("条目路径超出了解压目录")
+	ErrLinkEscape       = errors.New("链接指向了解压目录之外")
+	ErrSizeLimit        = errors.New("解压后总大小超过限制")
+	ErrEntryLimit       = errors.New("条目数量超过限制")
+	ErrCompressionRatio = errors.New("压缩比超过限制")
+)
+
+// 解压单个条目失败时返回的错误, Entry为压缩包中的条目名称
+type ExtractError struct {
+	Entry string
+	Err   error
+}
+
+func (e *ExtractError) Error() string {
+	return fmt.Sprintf("解压条目失败! 条目: %s 错误信息: %v", e.Entry, e.Err)
+}
+
+func (e *ExtractError) Unwrap() error {
+	return e.Err
+}
+
+// 解压选项, 零值与原有的UnTar、DeCompressGzip行为一致
+type ExtractOptions struct {
+	// 安全模式: 拒绝绝对路径、路径穿越以及指向解压目录之外的软链接和硬链接
+	Secure bool
+	// 解压后的总大小上限(字节), 0表示不限制
+	MaxTotalSize int64
+	// 条目数量上限, 0表示不限制
+	MaxEntries int
+	// 解压后总大小与压缩包大小的比值上限, 用于防御压缩炸弹, 0表示不限制
+	MaxCompressionRatio float64
+
+	// 恢复条目记录的uid/gid, 通常需要root权限
+	SameOwner bool
+	// 恢复完整的权限位(包括setuid、setgid、sticky), 不受umask影响
+	PreservePermissions bool
+	// 恢复修改时间和访问时间
+	PreserveTimes bool
+	// 恢复PAX记录中的扩展属性
+	PreserveXattrs bool
+	// 创建FIFO、字符设备和块设备, 关闭时这些条目会被跳过
+	SpecialFiles bool
+
+	// 进度回调; tar系列格式以读取的压缩包字节数计算进度, zip以解压后的字节数计算
+	Progress ProgressFunc
+	// 解压前遍历一次压缩包统计解压后的大小和条目数, 目标文件系统空间或inode不足时拒绝解压
+	CheckSpace bool
+}
+
+// 处理外部来源压缩包时推荐使用的安全解压选项
+func DefaultSecureExtractOptions() ExtractOptions {
+	return ExtractOptions{
+		Secure:              true,
+		MaxTotalSize:        10 << 30,
+		MaxEntries:          100000,
+		MaxCompressionRatio: 200,
+	}
+}
+
+// 尽可能完整地还原压缩包内容, 效果与 tar -xpf 相同
+func PreserveAllExtractOptions() ExtractOptions {
+	return ExtractOptions{
+		SameOwner:           true,
+		PreservePermissions: true,
+		PreserveTimes:       true,
+		PreserveXattrs:      true,
+		SpecialFiles:        true,
+	}
+}
+
+// tar.Reader以及其他格式适配后的条目读取接口
+type entryReader interface {
+	Next() (*tar.Header, error)
+	io.Reader
+}
+
+// 解压过程的状态
+type extractor struct {
+	ctx         context.Context
+	dest        string
+	opts        ExtractOptions
+	archive     *archiveReader
+	archiveSize int64 // 压缩包文件大小, 用于计算压缩比
+	totalSize   int64
+	entries     int
+	dirs        []*tar.Header // 目录的元数据在全部条目写入后再恢复
+	// 条目过滤, 返回false的条目会被跳过, 为nil时解压全部条目
+	filter func(hdr *tar.Header) bool
+}
+
+func newExtractor(ctx context.Context, dest string, archive *archiveReader, opts ExtractOptions) (*extractor, error) {
+	absDest, err := filepath.Abs(dest)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(absDest, 0755); err != nil {
+		return nil, err
+	}
+	if opts.Secure {
+		// 解压目录本身可能是软链接, 以真实路径作为边界
+		if absDest, err = filepath.EvalSymlinks(absDest); err != nil {
+			return nil, err
+		}
+	}
+	return &extractor{ctx: ctx, dest: absDest, opts: opts, archive: archive, archiveSize: archive.size}, nil
+}
+
+// 逐个读取条目并写入磁盘
+func (e *extractor) extractAll(er entryReader) error {
+	for {
+		if err := e.ctx.Err(); err != nil {
+			return err
+		}
+		hdr, err := er.Next()
+		if err == io.EOF {
+			return e.finish()
+		} else if err != nil {
+			return err
+		}
+		if hdr == nil || (e.filter != nil && !e.filter(hdr)) {
+			continue
+		}
+		if err = e.extractEntry(hdr, er); err != nil {
+			return &ExtractError{Entry: hdr.Name, Err: err}
+		}
+	}
+}
+
+func (e *extractor) extractEntry(hdr *tar.Header, r io.Reader) error {
+	if err := e.checkLimits(hdr); err != nil {
+		return err
+	}
+	path, err := e.resolvePath(hdr.Name)
+	if err != nil {
+		return err
+	}
+
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		if err = removeExisting(path, true); err != nil {
+			return err
+		}
+		// 先保证目录可写, 最终权限在finish中恢复
+		if err = os.MkdirAll(path, os.FileMode(hdr.Mode).Perm()|0700); err != nil {
+			return err
+		}
+		e.dirs = append(e.dirs, hdr)
+		return nil
+	case tar.TypeReg, tar.TypeRegA:
+		if err = e.writeFile(path, hdr, r); err != nil {
+			return err
+		}
+	case tar.TypeSymlink:
+		if err = e.checkSymlink(path, hdr.Linkname); err != nil {
+			return err
+		}
+		if err = e.prepareParent(path); err != nil {
+			return err
+		}
+		if err = os.Symlink(hdr.Linkname, path); err != nil {
+			return err
+		}
+	case tar.TypeLink:
+		target, err := e.resolvePath(hdr.Linkname)
+		if errors.Is(err, ErrPathTraversal) || errors.Is(err, ErrAbsolutePath) {
+			return ErrLinkEscape
+		} else if err != nil {
+			return err
+		}
+		if err = e.checkHardlink(target); err != nil {
+			return err
+		}
+		if err = e.prepareParent(path); err != nil {
+			return err
+		}
+		// 硬链接与目标共享元数据, 无需单独恢复
+		return os.Link(target, path)
+	case tar.TypeFifo, tar.TypeChar, tar.TypeBlock:
+		if !e.opts.SpecialFiles {
+			return nil
+		}
+		if err = e.prepareParent(path); err != nil {
+			return err
+		}
+		if err = makeSpecialFile(path, hdr); err != nil {
+			return err
+		}
+	default:
+		// 其他类型的条目(如PAX全局头)不需要写入磁盘
+		return nil
+	}
+	return e.applyMeta(path, hdr)
+}
+
+// 按需恢复属主、权限、扩展属性和时间, 顺序不能调整: chown会清除setuid位, 其他修改会更新时间
+func (e *extractor) applyMeta(path string, hdr *tar.Header) error {
+	isSymlink := hdr.Typeflag == tar.TypeSymlink
+	if e.opts.SameOwner {
+		if err := os.Lchown(path, hdr.Uid, hdr.Gid); err != nil {
+			return err
+		}
+	}
+	if !isSymlink {
+		mode := hdr.FileInfo().Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+		if e.opts.PreservePermissions {
+			if err := os.Chmod(path, mode); err != nil {
+				return err
+			}
+		} else if hdr.Typeflag == tar.TypeDir && mode&0700 != 0700 {
+			// 创建时为了写入子条目补充了属主权限, 这里还原
+			if err := os.Chmod(path, mode.Perm()); err != nil {
+				return err
+			}
+		}
+	}
+	if e.opts.PreserveXattrs && !isSymlink {
+		for key, value := range hdr.PAXRecords {
+			if !strings.HasPrefix(key, paxXattrPrefix) {
+				continue
+			}
+			if err := setXattr(path, strings.TrimPrefix(key, paxXattrPrefix), []byte(value)); err != nil && !isXattrUnsupported(err) {
+				return err
+			}
+		}
+	}
+	if e.opts.PreserveTimes {
+		return setFileTimes(path, hdr.AccessTime, hdr.ModTime)
+	}
+	return nil
+}
+
+// 全部条目写入后, 由深到浅恢复目录的元数据
+func (e *extractor) finish() error {
+	for i := len(e.dirs) - 1; i >= 0; i-- {
+		hdr := e.dirs[i]
+		path, err := e.resolvePath(hdr.Name)
+		if err == nil {
+			// 目录可能已被后续条目替换为软链接, Chmod和Chtimes会跟随软链接, 只处理仍然是目录的路径
+			var info os.FileInfo
+			if info, err = os.Lstat(path); isNotExistOrNotDir(err) || err == nil && !info.IsDir() {
+				continue
+			}
+		}
+		if err == nil {
+			err = e.applyMeta(path, hdr)
+		}
+		if err != nil {
+			return &ExtractError{Entry: hdr.Name, Err: err}
+		}
+	}
+	return nil
+}
+
+// 创建上级目录并删除目标位置已存在的非目录文件
+func (e *extractor) prepareParent(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return removeExisting(path, false)
+}
+
+// 删除目标位置已存在的条目, 目录条目遇到已存在的目录时保留
+func removeExisting(path string, isDir bool) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if isDir && info.IsDir() {
+		return nil
+	}
+	return os.Remove(path)
+}
+
+// 检查条目数量、总大小以及压缩比限制
+func (e *extractor) checkLimits(hdr *tar.Header) error {
+	e.entries++
+	if e.opts.MaxEntries > 0 && e.entries > e.opts.MaxEntries {
+		return ErrEntryLimit
+	}
+	if hdr.Typeflag == tar.TypeReg || hdr.Typeflag == tar.TypeRegA {
+		e.totalSize += hdr.Size
+	}
+	if e.opts.MaxTotalSize > 0 && e.totalSize > e.opts.MaxTotalSize {
+		return ErrSizeLimit
+	}
+	if e.opts.MaxCompressionRatio > 0 && e.archiveSize > 0 &&
+		float64(e.totalSize)/float64(e.archiveSize) > e.opts.MaxCompressionRatio {
+		return ErrCompressionRatio
+	}
+	return nil
+}
+
+// 计算条目在磁盘上的路径, 安全模式下校验路径不会超出解压目录
+func (e *extractor) resolvePath(name string) (string, error) {
+	if !e.opts.Secure {
+		return filepath.Join(e.dest, name), nil
+	}
+	if filepath.IsAbs(name) || strings.HasPrefix(name, "/") || strings.HasPrefix(name, "\\") || filepath.VolumeName(name) != "" {
+		return "", ErrAbsolutePath
+	}
+	path := filepath.Join(e.dest, name)
+	if !isWithinDir(e.dest, path) {
+		return "", ErrPathTraversal
+	}
+	// 已经解压出来的软链接可能把后续条目带到目录之外, 校验已存在的上级目录的真实路径
+	if realParent, err := evalExistingPath(filepath.Dir(path)); err != nil {
+		return "", err
+	} else if !isWithinDir(e.dest, realParent) {
+		return "", ErrLinkEscape
+	}
+	return path, nil
+}
+
+/*
+安全模式下校验软链接目标, 相对路径按链接所在目录的真实路径解析
+1. 已解压的软链接可能让链接所在目录与条目名称对应的目录不同, 如 a -> . 之后的 a/b -> ..
+2. 链接目标中经过的已解压的软链接同样需要解析, 如 b -> . 之后的 c -> b/../x
+*/
+func (e *extractor) checkSymlink(path, linkname string) error {
+	if !e.opts.Secure {
+		return nil
+	}
+	if filepath.IsAbs(linkname) || strings.HasPrefix(linkname, "/") {
+		return ErrLinkEscape
+	}
+	realParent, err := evalExistingPath(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	target, err := evalLinkTarget(realParent, linkname)
+	if err != nil {
+		return err
+	}
+	if !isWithinDir(e.dest, target) {
+		return ErrLinkEscape
+	}
+	return nil
+}
+
+/*
+从dir开始逐段解析软链接目标, 已存在的部分跟随软链接, 而不是像filepath.Join那样先按字面消去..
+不存在的路径之后再出现..时, 最终位置取决于之后解压的条目, 无法确定, 视为越界
+*/
+func evalLinkTarget(dir, linkname string) (string, error) {
+	current := dir
+	missing := false
+	for _, part := range strings.Split(filepath.ToSlash(linkname), "/") {
+		switch {
+		case part == "" || part == ".":
+			continue
+		case part == ".." && missing:
+			return "", ErrLinkEscape
+		case part == "..":
+			current = filepath.Dir(current)
+			continue
+		}
+		current = filepath.Join(current, part)
+		if missing {
+			continue
+		}
+		real, err := filepath.EvalSymlinks(current)
+		if isNotExistOrNotDir(err) {
+			missing = true
+			continue
+		} else if err != nil {
+			return "", err
+		}
+		current = real
+	}
+	return current, nil
+}
+
+// 安全模式下校验硬链接目标的真实路径位于解压目录之内
+func (e *extractor) checkHardlink(target string) error {
+	if !e.opts.Secure {
+		return nil
+	}
+	realParent, err := evalExistingPath(filepath.Dir(target))
+	if err != nil {
+		return err
+	}
+	if !isWithinDir(e.dest, filepath.Join(realParent, filepath.Base(target))) {
+		return ErrLinkEscape
+	}
+	return nil
+}
+
+func (e *extractor) writeFile(path string, hdr *tar.Header, r io.Reader) error {
+	// 目标位置已存在的文件或软链接先删除, 避免通过软链接或硬链接写到其他位置
+	if err := e.prepareParent(path); err != nil {
+		return err
+	}
+	// 先写入临时文件, 取消或失败时不会留下写了一半的文件
+	file, err := createTempSibling(path, os.FileMode(hdr.Mode).Perm())
+	if err != nil {
+		return err
+	}
+	// tar.Reader保证最多读取hdr.Size字节, 其他格式的适配器同样需要遵守
+	_, err = copyContext(e.ctx, file, r, func(int64) {
+		e.report(hdr.Name)
+	})
+	return finishTempSibling(file, path, err)
+}
+
+func (e *extractor) report(name string) {
+	if e.opts.Progress == nil || e.archive == nil || e.archive.progress == nil {
+		return
+	}
+	done, total := e.archive.progress()
+	e.opts.Progress(Progress{Done: done, Total: total, Path: name})
+}
+
+// 判断path是否位于dir之内(包括dir本身), 两者都需要是Clean之后的路径
+func isWithinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// 解析路径中已存在部分的软链接, 不存在的部分原样拼接
+func evalExistingPath(path string) (string, error) {
+	var missing []string
+	for {
+		real, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			for i := len(missing) - 1; i >= 0; i-- {
+				real = filepath.Join(real, missing[i])
+			}
+			return real, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return "", err
+		}
+		missing = append(missing, filepath.Base(path))
+		path = parent
+	}
+}
+
+// 使用指定选项解开tar包
+func UnTarWithOptions(tarball, target string, opts ExtractOptions) error {
+	return extractArchive(context.Background(), tarball, target, FormatTar, opts)
+}
+
+// 支持取消和进度回调的解开tar包, 进度按已读取的tar包字节数计算
+func UnTarWithContext(ctx context.Context, tarball, target string, progress ProgressFunc) error {
+	return extractArchive(ctx, tarball, target, FormatTar, ExtractOptions{Progress: progress})
+}
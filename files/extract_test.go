@@ -0,0 +1,248 @@
+package files
+
+import (
+	"archive/tar"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// 构造测试用的tar包
+func writeTestTar(t *testing.T, path string, headers []*tar.Header) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tw := tar.NewWriter(f)
+	for _, hdr := range headers {
+		if hdr.Typeflag == tar.TypeReg {
+			hdr.Size = int64(len(hdr.Name))
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte(hdr.Name)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnTarWithOptionsSecure(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []*tar.Header
+		opts    ExtractOptions
+		wantErr error
+	}{
+		{
+			"正常压缩包",
+			[]*tar.Header{
+				{Name: "app/", Typeflag: tar.TypeDir, Mode: 0755},
+				{Name: "app/conf.yml", Typeflag: tar.TypeReg, Mode: 0644},
+				{Name: "app/current", Typeflag: tar.TypeSymlink, Linkname: "conf.yml"},
+			},
+			DefaultSecureExtractOptions(),
+			nil,
+		},
+		{
+			"路径穿越",
+			[]*tar.Header{{Name: "../../etc/cron.d/x", Typeflag: tar.TypeReg, Mode: 0644}},
+			DefaultSecureExtractOptions(),
+			ErrPathTraversal,
+		},
+		{
+			"绝对路径",
+			[]*tar.Header{{Name: "/etc/passwd", Typeflag: tar.TypeReg, Mode: 0644}},
+			DefaultSecureExtractOptions(),
+			ErrAbsolutePath,
+		},
+		{
+			"软链接指向目录之外",
+			[]*tar.Header{{Name: "etc", Typeflag: tar.TypeSymlink, Linkname: "../../etc"}},
+			DefaultSecureExtractOptions(),
+			ErrLinkEscape,
+		},
+		{
+			"经过已解压的软链接指向目录之外",
+			[]*tar.Header{
+				{Name: "a", Typeflag: tar.TypeSymlink, Linkname: "."},
+				{Name: "a/b", Typeflag: tar.TypeSymlink, Linkname: ".."},
+			},
+			DefaultSecureExtractOptions(),
+			ErrLinkEscape,
+		},
+		{
+			"链接目标经过已解压的软链接",
+			[]*tar.Header{
+				{Name: "b", Typeflag: tar.TypeSymlink, Linkname: "."},
+				{Name: "c", Typeflag: tar.TypeSymlink, Linkname: "b/../x"},
+			},
+			DefaultSecureExtractOptions(),
+			ErrLinkEscape,
+		},
+		{
+			"链接目标在不存在的路径之后出现..",
+			[]*tar.Header{{Name: "c", Typeflag: tar.TypeSymlink, Linkname: "d/../x"}},
+			DefaultSecureExtractOptions(),
+			ErrLinkEscape,
+		},
+		{
+			"链接目标指向上级目录中的文件",
+			[]*tar.Header{
+				{Name: "shared/lib.so", Typeflag: tar.TypeReg, Mode: 0644},
+				{Name: "app/lib.so", Typeflag: tar.TypeSymlink, Linkname: "../shared/lib.so"},
+			},
+			DefaultSecureExtractOptions(),
+			nil,
+		},
+		{
+			"经过已解压的软链接创建硬链接",
+			[]*tar.Header{
+				{Name: "conf.yml", Typeflag: tar.TypeReg, Mode: 0644},
+				{Name: "a", Typeflag: tar.TypeSymlink, Linkname: "."},
+				{Name: "link.yml", Typeflag: tar.TypeLink, Linkname: "a/conf.yml"},
+			},
+			DefaultSecureExtractOptions(),
+			nil,
+		},
+		{
+			"硬链接指向目录之外",
+			[]*tar.Header{{Name: "passwd", Typeflag: tar.TypeLink, Linkname: "/etc/passwd"}},
+			DefaultSecureExtractOptions(),
+			ErrLinkEscape,
+		},
+		{
+			"条目数量超过限制",
+			[]*tar.Header{
+				{Name: "a", Typeflag: tar.TypeReg, Mode: 0644},
+				{Name: "b", Typeflag: tar.TypeReg, Mode: 0644},
+			},
+			ExtractOptions{Secure: true, MaxEntries: 1},
+			ErrEntryLimit,
+		},
+		{
+			"总大小超过限制",
+			[]*tar.Header{{Name: "large-file", Typeflag: tar.TypeReg, Mode: 0644}},
+			ExtractOptions{Secure: true, MaxTotalSize: 4},
+			ErrSizeLimit,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tarball := filepath.Join(dir, "test.tar")
+			writeTestTar(t, tarball, tt.headers)
+			err := UnTarWithOptions(tarball, filepath.Join(dir, "out"), tt.opts)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UnTarWithOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var extractErr *ExtractError
+			if tt.wantErr != nil && (!errors.As(err, &extractErr) || extractErr.Entry == "") {
+				t.Errorf("UnTarWithOptions() error = %v, want *ExtractError with entry name", err)
+			}
+		})
+	}
+}
+
+// 目录条目被后续的软链接替换后, 不能通过软链接修改其他目录的权限
+func TestUnTarDirReplacedBySymlink(t *testing.T) {
+	dir := t.TempDir()
+	tarball := filepath.Join(dir, "test.tar")
+	writeTestTar(t, tarball, []*tar.Header{
+		{Name: "d/", Typeflag: tar.TypeDir, Mode: 0700},
+		{Name: "d", Typeflag: tar.TypeSymlink, Linkname: "."},
+	})
+	out := filepath.Join(dir, "out")
+	mustDo(t, os.MkdirAll(out, 0755))
+	mustDo(t, os.Chmod(out, 0755))
+	if err := UnTarWithOptions(tarball, out, PreserveAllExtractOptions()); err != nil {
+		t.Fatalf("UnTarWithOptions() error = %v", err)
+	}
+	info, err := os.Stat(out)
+	mustDo(t, err)
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("解压目录的权限 = %v, want %v", info.Mode().Perm(), os.FileMode(0755))
+	}
+}
+
+func TestCompressTarRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "app")
+	if err := os.MkdirAll(filepath.Join(source, "conf"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2001, 2, 3, 4, 5, 6, 123456789, time.UTC)
+	mustDo(t, os.WriteFile(filepath.Join(source, "conf", "app.yml"), []byte("port: 8080\n"), 0640))
+	mustDo(t, os.Chtimes(filepath.Join(source, "conf", "app.yml"), mtime, mtime))
+	mustDo(t, os.Link(filepath.Join(source, "conf", "app.yml"), filepath.Join(source, "app.yml")))
+	mustDo(t, os.Symlink("conf/app.yml", filepath.Join(source, "current.yml")))
+
+	tarball := filepath.Join(dir, "app.tar")
+	mustDo(t, CompressTar(source, tarball))
+	out := filepath.Join(dir, "out")
+	mustDo(t, UnTarWithOptions(tarball, out, PreserveAllExtractOptions()))
+
+	want, err := CalcDirHash(source)
+	mustDo(t, err)
+	got, err := CalcDirHash(filepath.Join(out, "app"))
+	mustDo(t, err)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CalcDirHash() = %v, want %v", got, want)
+	}
+	info, err := os.Stat(filepath.Join(out, "app", "conf", "app.yml"))
+	mustDo(t, err)
+	if !info.ModTime().Equal(mtime) || info.Mode().Perm() != 0640 {
+		t.Errorf("app.yml mtime = %v mode = %v, want %v %v", info.ModTime(), info.Mode().Perm(), mtime, os.FileMode(0640))
+	}
+	linkInfo, err := os.Stat(filepath.Join(out, "app", "app.yml"))
+	mustDo(t, err)
+	if !os.SameFile(info, linkInfo) {
+		t.Errorf("app.yml is not a hard link of conf/app.yml")
+	}
+	if link, err := os.Readlink(filepath.Join(out, "app", "current.yml")); err != nil || link != "conf/app.yml" {
+		t.Errorf("Readlink(current.yml) = %q, %v, want conf/app.yml", link, err)
+	}
+}
+
+func mustDo(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnTarSpecialFiles(t *testing.T) {
+	dir := t.TempDir()
+	tarball := filepath.Join(dir, "special.tar")
+	writeTestTar(t, tarball, []*tar.Header{
+		{Name: "app/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "app/pipe", Typeflag: tar.TypeFifo, Mode: 0640},
+	})
+	for _, special := range []bool{false, true} {
+		out := filepath.Join(dir, "skip")
+		if special {
+			out = filepath.Join(dir, "create")
+		}
+		mustDo(t, UnTarWithOptions(tarball, out, ExtractOptions{SpecialFiles: special}))
+		info, err := os.Lstat(filepath.Join(out, "app", "pipe"))
+		if !special {
+			if !os.IsNotExist(err) {
+				t.Errorf("SpecialFiles关闭时 Lstat(pipe) error = %v, want 不存在", err)
+			}
+			continue
+		}
+		if err != nil || info.Mode()&os.ModeNamedPipe == 0 {
+			t.Errorf("SpecialFiles开启时 pipe = %v, %v, want FIFO", info, err)
+		}
+	}
+}
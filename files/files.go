@@ -1,9 +1,8 @@
 package files
 
 import (
-	"archive/tar"
 	"bufio"
-	"compress/gzip"
+	"context"
 	"crypto/md5"
 	"encoding/csv"
 	"encoding/hex"
@@ -13,7 +12,6 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
-	"strings"
 )
 
 // 获取文件的MD5值
@@ -72,25 +70,15 @@ func GetFiles(dirPath string) ([]string, error) {
 	return fileArray, err
 }
 
-// 根据glob规则获取指定目录下匹配的所有文件
+// 根据glob规则获取指定目录下匹配的所有文件(不包括目录), 结果按路径排序; 需要更多过滤条件时请使用Find
 func SearchFileInPath(dirPath string, fileNameGlobReg string) ([]string, error) {
-	var goalFileArrary []string
-	fileArray, err := GetFiles(dirPath)
-	if err == nil {
-		for _, eachFilePath := range fileArray {
-			fileBaseName := filepath.Base(eachFilePath)
-			match, err := filepath.Match(fileNameGlobReg, fileBaseName)
-			if err != nil {
-				return goalFileArrary, err
-			} else if match {
-				goalFileArrary = append(goalFileArrary, eachFilePath)
-			}
-		}
-	}
-	return goalFileArrary, err
+	return FindPaths(context.Background(), dirPath, FindQuery{
+		Name:  fileNameGlobReg,
+		Types: []FileType{FileTypeFile, FileTypeSymlink, FileTypeFifo, FileTypeDevice, FileTypeOther},
+	})
 }
 
-// 拷贝文件
+// 拷贝文件, Linux下会优先使用reflink和copy_file_range, 并保留稀疏文件的空洞
 func Copy(srcFile, dstFile string) error {
 	out, err := os.Create(dstFile)
 	if err != nil {
@@ -108,7 +96,7 @@ func Copy(srcFile, dstFile string) error {
 		return err
 	}
 
-	_, err = io.Copy(out, in)
+	_, err = copyFileData(context.Background(), out, in, nil)
 	if err != nil {
 		return err
 	}
@@ -124,32 +112,24 @@ func CopySymLink(source, dest string) error {
 	return os.Symlink(link, dest)
 }
 
-// 逐行读取文件
+// 逐行读取文件, 每行保留行尾的换行符; 大文件请使用ReadLines或LineReader流式读取
 func ReadFileAsLines(filePath string) ([]string, error) {
 	var resultSlice []string
-	f, err := os.Open(filePath)
+	lr, err := OpenLineReader(filePath, LineReaderOptions{MaxLineLength: -1, KeepNewline: true})
 	if err != nil {
 		return resultSlice, err
 	}
-	defer f.Close()
-
-	reader := bufio.NewReader(f)
-	for {
-		line, err := reader.ReadString('\n')
-		if err != nil {
-			if err == io.EOF {
-				// 获取最后一行内容后退出循环
-				resultSlice = append(resultSlice, line)
-				break
-			}
-			return resultSlice, fmt.Errorf("读取文件内容失败,换行符读取异常! ERROR: %s", err.Error())
-		}
-		resultSlice = append(resultSlice, line)
+	defer lr.Close()
+	for lr.Next() {
+		resultSlice = append(resultSlice, lr.Text())
+	}
+	if err = lr.Err(); err != nil {
+		return resultSlice, fmt.Errorf("读取文件内容失败,换行符读取异常! ERROR: %s", err.Error())
 	}
-	return resultSlice, err
+	return resultSlice, nil
 }
 
-// 逐行读取文件为字节切片
+// 读取整个文件为字节切片, 大文件请使用LineReader流式读取
 func ReadFileAsByteSlice(filePath string) ([]byte, error) {
 	bytes, err := os.ReadFile(filePath)
 	return bytes, err
@@ -161,6 +141,11 @@ func WriteByteSlice2File(filePath string, byteSlice []byte) error {
 	return err
 }
 
+// 将字节切片数据原子写入文件, 写入过程中崩溃不会留下内容不完整的文件
+func WriteByteSlice2FileAtomic(filePath string, byteSlice []byte, opts AtomicWriteOptions) error {
+	return AtomicWriteFile(filePath, byteSlice, opts)
+}
+
 /*
 将字符串切片写入文件
 如果newLine为true，则文件写入新的一行
@@ -192,161 +177,56 @@ func WriteStringSlice2File(filePath string, stringSlice []string, newLine bool,
 	return err
 }
 
-// 解压gzip文件
-func DeCompressGzip(gzipFile, dest string) error {
-	// 打开准备解压的gzip文件
-	srcFile, err := os.Open(gzipFile)
-	if err != nil {
-		return err
-	}
-	defer srcFile.Close()
-
-	// 将打开的文件先解压
-	gr, err := gzip.NewReader(srcFile)
+// 将字符串切片原子写入文件, 如果newLine为true, 每个元素写为一行; 原子写入只能整体替换, 不支持追加
+func WriteStringSlice2FileAtomic(filePath string, stringSlice []string, newLine bool, opts AtomicWriteOptions) error {
+	file, err := NewAtomicFile(filePath, opts)
 	if err != nil {
 		return err
 	}
-	defer gr.Close()
-
-	// 通过 gr 创建 tar.Reade
-	tr := tar.NewReader(gr)
-	// 现在已经获得了 tar.Reader 结构了,只需要循环里面的数据写入文件就可以了
-	for {
-		hdr, err := tr.Next()
-
-		switch {
-		case err == io.EOF:
-			return nil
-		case err != nil:
-			return err
-		case hdr == nil:
-			continue
+	defer file.Abort()
+	writer := bufio.NewWriter(file)
+	for _, elementString := range stringSlice {
+		if newLine {
+			elementString = elementString + "\n"
 		}
-
-		// 处理下保存路径,将要保存的目录加上 header 中的 Name
-		// 这个变量保存的有可能是目录,有可能是文件,所以就叫 FileDir了
-		dstFileDir := filepath.Join(dest, hdr.Name)
-
-		// 根据 header 的 Typeflag 字段,判断文件的类型
-		switch hdr.Typeflag {
-		case tar.TypeDir: // 如果是目录时候,创建目录
-			// 判断下目录是否存在,不存在就创建
-			if b := PathIsExist(dstFileDir); !b {
-				// 使用 MkdirAll 不使用 Mkdir ,就类似 Linux 终端下的 mkdir -p,
-				// 可以递归创建每一级目录
-				if err := os.MkdirAll(dstFileDir, 0775); err != nil {
-					return err
-				}
-			}
-		case tar.TypeReg: // 如果是文件就写入到磁盘
-			// 创建一个可以读写的文件,权限就使用 header 中记录的权限
-			// 因为操作系统的 FileMode 是 int32 类型的,hdr 中的是 int64,所以转换下
-			file, err := os.OpenFile(dstFileDir, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(hdr.Mode))
-			if err != nil {
-				return err
-			}
-			_, err = io.Copy(file, tr)
-			if err != nil {
-				return err
-			}
-			// 不要忘记关闭打开的文件,因为它是在 for 循环中,不能使用 defer
-			// 如果想使用 defer 就放在一个单独的函数中
-			file.Close()
+		if _, err = writer.WriteString(elementString); err != nil {
+			return err
 		}
-
 	}
-}
-
-// 将文件打包为tar
-func CompressTar(source, target string) error {
-	tarfile, err := os.Create(target)
-	if err != nil {
+	if err = writer.Flush(); err != nil {
 		return err
 	}
-	defer tarfile.Close()
+	return file.Commit()
+}
 
-	tarball := tar.NewWriter(tarfile)
-	defer tarball.Close()
+// 解压gzip文件
+func DeCompressGzip(gzipFile, dest string) error {
+	return DeCompressGzipWithOptions(gzipFile, dest, ExtractOptions{})
+}
 
-	info, err := os.Stat(source)
-	if err != nil {
-		return nil
-	}
+// 使用指定选项解压gzip文件
+func DeCompressGzipWithOptions(gzipFile, dest string, opts ExtractOptions) error {
+	return extractArchive(context.Background(), gzipFile, dest, FormatTarGz, opts)
+}
 
-	var baseDir string
-	if info.IsDir() {
-		baseDir = filepath.Base(source)
-	}
+// 将文件打包为tar.gz
+func CompressGzip(source, target string) error {
+	return CreateArchive(source, target, FormatTarGz, ArchiveOptions{})
+}
 
-	return filepath.Walk(source,
-		func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-			header, err := tar.FileInfoHeader(info, info.Name())
-			if err != nil {
-				return err
-			}
-
-			if baseDir != "" {
-				header.Name = filepath.Join(baseDir, strings.TrimPrefix(path, source))
-			}
-
-			if err := tarball.WriteHeader(header); err != nil {
-				return err
-			}
-
-			if info.IsDir() {
-				return nil
-			}
-
-			file, err := os.Open(path)
-			if err != nil {
-				return err
-			}
-			defer file.Close()
-			_, err = io.Copy(tarball, file)
-			return err
-		})
+// 将文件打包为tar, 保留软链接、硬链接、属主、修改时间和扩展属性
+func CompressTar(source, target string) error {
+	return CreateArchive(source, target, FormatTar, ArchiveOptions{})
+}
+
+// 支持取消和进度回调的打包tar, 进度的总字节数为待打包文件的大小之和
+func CompressTarWithContext(ctx context.Context, source, target string, progress ProgressFunc) error {
+	return CreateArchiveContext(ctx, source, target, FormatTar, ArchiveOptions{Progress: progress})
 }
 
 // 解开tar包
 func UnTar(tarball, target string) error {
-	reader, err := os.Open(tarball)
-	if err != nil {
-		return err
-	}
-	defer reader.Close()
-	tarReader := tar.NewReader(reader)
-
-	for {
-		header, err := tarReader.Next()
-		if err == io.EOF {
-			break
-		} else if err != nil {
-			return err
-		}
-
-		path := filepath.Join(target, header.Name)
-		info := header.FileInfo()
-		if info.IsDir() {
-			if err = os.MkdirAll(path, info.Mode()); err != nil {
-				return err
-			}
-			continue
-		}
-
-		file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode())
-		if err != nil {
-			return err
-		}
-		defer file.Close()
-		_, err = io.Copy(file, tarReader)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return UnTarWithOptions(tarball, target, ExtractOptions{})
 }
 
 // this is the default sort order of golang ReadDir
@@ -388,3 +268,18 @@ func WriteCSV(records [][]string, csvFile string) error {
 	writer.Comma = ','
 	return writer.WriteAll(records)
 }
+
+// 原子写入CSV文件, 写入过程中崩溃不会留下内容不完整的文件
+func WriteCSVAtomic(records [][]string, csvFile string, opts AtomicWriteOptions) error {
+	file, err := NewAtomicFile(csvFile, opts)
+	if err != nil {
+		return err
+	}
+	defer file.Abort()
+	writer := csv.NewWriter(file)
+	writer.Comma = ','
+	if err = writer.WriteAll(records); err != nil {
+		return err
+	}
+	return file.Commit()
+}
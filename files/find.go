@@ -0,0 +1,345 @@
+package files
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 文件查找条件, 未设置的条件不参与过滤, 全部条件同时满足才算匹配
+type FindQuery struct {
+	// 文件名的glob规则, 如 *.log
+	Name string
+	// 文件名的正则表达式
+	NameRegex string
+	// 完整路径(查找的根目录与相对路径拼接)的正则表达式
+	PathRegex string
+	// 文件大小范围, 单位字节, 0表示不限制
+	MinSize int64
+	MaxSize int64
+	// 修改时间范围, 零值表示不限制
+	ModifiedAfter  time.Time
+	ModifiedBefore time.Time
+	// 文件类型, 为空时不限制
+	Types []FileType
+	// 最大深度, 根目录下的直接条目深度为1, 0表示不限制
+	MaxDepth int
+	// gitignore风格的规则, 相对于根目录匹配, 匹配的目录不会被遍历也不会出现在结果中
+	ExcludeDirs []string
+	// 跟随软链接, 结果中的软链接按其指向的文件判断类型和大小, 指向目录时会继续遍历
+	FollowSymlinks bool
+	// 并发遍历的goroutine数量, 默认为CPU核数
+	Workers int
+}
+
+// 查找结果, Err不为nil时表示遍历该路径失败, 其余字段无效
+type FindResult struct {
+	Path  string
+	Info  os.FileInfo
+	Depth int
+	Err   error
+}
+
+// 文件模式对应的文件类型
+func fileTypeOf(mode os.FileMode) FileType {
+	switch {
+	case mode.IsRegular():
+		return FileTypeFile
+	case mode.IsDir():
+		return FileTypeDir
+	case mode&os.ModeSymlink != 0:
+		return FileTypeSymlink
+	case mode&os.ModeNamedPipe != 0:
+		return FileTypeFifo
+	case mode&os.ModeDevice != 0:
+		return FileTypeDevice
+	}
+	return FileTypeOther
+}
+
+/*
+并发查找根目录下符合条件的文件, 结果通过channel流式返回, 查找结束或ctx取消后关闭
+1. 结果的顺序不固定, 需要固定顺序时请使用FindPaths
+2. 某个目录无法读取时返回Err不为nil的结果并继续查找其他目录
+3. 根目录本身不会出现在结果中; root不是目录时只匹配root本身, 与find命令的行为一致
+*/
+func Find(ctx context.Context, root string, q FindQuery) (<-chan FindResult, error) {
+	f, err := newFinder(ctx, root, q)
+	if err != nil {
+		return nil, err
+	}
+	go f.run()
+	return f.out, nil
+}
+
+// 查找并返回按路径排序的结果, 遍历出错时继续查找, 返回已找到的结果和第一个错误
+func FindPaths(ctx context.Context, root string, q FindQuery) ([]string, error) {
+	results, err := Find(ctx, root, q)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	var firstErr error
+	for result := range results {
+		if result.Err != nil {
+			if firstErr == nil {
+				firstErr = result.Err
+			}
+			continue
+		}
+		paths = append(paths, result.Path)
+	}
+	sort.Strings(paths)
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+	return paths, firstErr
+}
+
+type findDir struct {
+	path      string
+	depth     int
+	ancestors [][2]uint64 // 跟随软链接时从根目录到该目录的全部目录, 用于发现循环
+}
+
+type finder struct {
+	ctx       context.Context
+	root      string
+	q         FindQuery
+	nameRegex *regexp.Regexp
+	pathRegex *regexp.Regexp
+	exclude   *PathMatcher
+	types     map[FileType]bool
+	out       chan FindResult
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []findDir
+	pending int // 已入队但尚未处理完成的目录数量
+}
+
+func newFinder(ctx context.Context, root string, q FindQuery) (*finder, error) {
+	f := &finder{ctx: ctx, root: root, q: q, out: make(chan FindResult, 64)}
+	f.cond = sync.NewCond(&f.mu)
+	if q.Name != "" {
+		if _, err := filepath.Match(q.Name, ""); err != nil {
+			return nil, fmt.Errorf("无效的文件名规则: %q %v", q.Name, err)
+		}
+	}
+	var err error
+	if q.NameRegex != "" {
+		if f.nameRegex, err = regexp.Compile(q.NameRegex); err != nil {
+			return nil, err
+		}
+	}
+	if q.PathRegex != "" {
+		if f.pathRegex, err = regexp.Compile(q.PathRegex); err != nil {
+			return nil, err
+		}
+	}
+	if f.exclude, err = NewPathMatcher(q.ExcludeDirs); err != nil {
+		return nil, err
+	}
+	if len(q.Types) > 0 {
+		f.types = map[FileType]bool{}
+		for _, t := range q.Types {
+			f.types[t] = true
+		}
+	}
+	if f.q.Workers <= 0 {
+		f.q.Workers = runtime.NumCPU()
+	}
+	return f, nil
+}
+
+func (f *finder) run() {
+	defer close(f.out)
+	info, err := f.stat(f.root)
+	if err != nil {
+		f.send(FindResult{Path: f.root, Err: err})
+		return
+	}
+	if !info.IsDir() {
+		if f.match(f.root, info) {
+			f.send(FindResult{Path: f.root, Info: info})
+		}
+		return
+	}
+	root := findDir{path: f.root}
+	root.ancestors, _ = f.descend(root, info)
+	f.push(root)
+
+	// ctx取消时唤醒等待中的worker
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-f.ctx.Done():
+			f.mu.Lock()
+			f.cond.Broadcast()
+			f.mu.Unlock()
+		case <-stop:
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < f.q.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f.worker()
+		}()
+	}
+	wg.Wait()
+}
+
+func (f *finder) push(dir findDir) {
+	f.mu.Lock()
+	f.queue = append(f.queue, dir)
+	f.pending++
+	f.cond.Signal()
+	f.mu.Unlock()
+}
+
+func (f *finder) worker() {
+	for {
+		f.mu.Lock()
+		for len(f.queue) == 0 && f.pending > 0 && f.ctx.Err() == nil {
+			f.cond.Wait()
+		}
+		if len(f.queue) == 0 || f.ctx.Err() != nil {
+			f.mu.Unlock()
+			return
+		}
+		dir := f.queue[len(f.queue)-1]
+		f.queue = f.queue[:len(f.queue)-1]
+		f.mu.Unlock()
+
+		f.walkDir(dir)
+
+		f.mu.Lock()
+		f.pending--
+		if f.pending == 0 {
+			f.cond.Broadcast()
+		}
+		f.mu.Unlock()
+	}
+}
+
+func (f *finder) walkDir(dir findDir) {
+	entries, err := os.ReadDir(dir.path)
+	if err != nil {
+		f.send(FindResult{Path: dir.path, Err: err})
+		return
+	}
+	depth := dir.depth + 1
+	for _, entry := range entries {
+		if f.ctx.Err() != nil {
+			return
+		}
+		path := filepath.Join(dir.path, entry.Name())
+		info, err := entry.Info()
+		if err == nil && f.q.FollowSymlinks && info.Mode()&os.ModeSymlink != 0 {
+			// 失效的软链接按软链接本身处理
+			if target, statErr := os.Stat(path); statErr == nil {
+				info = target
+			}
+		}
+		if err != nil {
+			if !os.IsNotExist(err) {
+				f.send(FindResult{Path: path, Err: err})
+			}
+			continue
+		}
+
+		if info.IsDir() {
+			if rel, err := filepath.Rel(f.root, path); err == nil && f.exclude.Match(filepath.ToSlash(rel), true) {
+				continue
+			}
+		}
+		if f.match(path, info) && !f.send(FindResult{Path: path, Info: info, Depth: depth}) {
+			return
+		}
+		if info.IsDir() && (f.q.MaxDepth <= 0 || depth < f.q.MaxDepth) {
+			ancestors, ok := f.descend(dir, info)
+			if !ok {
+				f.send(FindResult{Path: path, Err: fmt.Errorf("跟随软链接时发现循环")})
+				continue
+			}
+			f.push(findDir{path: path, depth: depth, ancestors: ancestors})
+		}
+	}
+}
+
+// 进入子目录时的上级目录链, 子目录已经在上级目录链中时返回false
+func (f *finder) descend(parent findDir, info os.FileInfo) ([][2]uint64, bool) {
+	if !f.q.FollowSymlinks {
+		return nil, true
+	}
+	dev, ino, _, ok := fileInode(info)
+	if !ok {
+		return nil, true
+	}
+	key := [2]uint64{dev, ino}
+	for _, ancestor := range parent.ancestors {
+		if ancestor == key {
+			return nil, false
+		}
+	}
+	ancestors := make([][2]uint64, len(parent.ancestors), len(parent.ancestors)+1)
+	copy(ancestors, parent.ancestors)
+	return append(ancestors, key), true
+}
+
+func (f *finder) stat(path string) (os.FileInfo, error) {
+	if f.q.FollowSymlinks {
+		return os.Stat(path)
+	}
+	return os.Lstat(path)
+}
+
+func (f *finder) match(path string, info os.FileInfo) bool {
+	name := filepath.Base(path)
+	if f.q.Name != "" {
+		if ok, _ := filepath.Match(f.q.Name, name); !ok {
+			return false
+		}
+	}
+	if f.nameRegex != nil && !f.nameRegex.MatchString(name) {
+		return false
+	}
+	if f.pathRegex != nil && !f.pathRegex.MatchString(path) {
+		return false
+	}
+	if f.types != nil && !f.types[fileTypeOf(info.Mode())] {
+		return false
+	}
+	if f.q.MinSize > 0 && info.Size() < f.q.MinSize {
+		return false
+	}
+	if f.q.MaxSize > 0 && info.Size() > f.q.MaxSize {
+		return false
+	}
+	if !f.q.ModifiedAfter.IsZero() && !info.ModTime().After(f.q.ModifiedAfter) {
+		return false
+	}
+	if !f.q.ModifiedBefore.IsZero() && !info.ModTime().Before(f.q.ModifiedBefore) {
+		return false
+	}
+	return true
+}
+
+func (f *finder) send(result FindResult) bool {
+	select {
+	case f.out <- result:
+		return true
+	case <-f.ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,59 @@
+package files
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFindPaths(t *testing.T) {
+	root := t.TempDir()
+	mustDo(t, os.MkdirAll(filepath.Join(root, "logs", "old"), 0755))
+	mustDo(t, os.MkdirAll(filepath.Join(root, "node_modules"), 0755))
+	for _, name := range []string{"app.log", "logs/a.log", "logs/old/b.log", "logs/c.txt", "node_modules/d.log"} {
+		mustDo(t, os.WriteFile(filepath.Join(root, name), []byte(name), 0644))
+	}
+	join := func(names ...string) []string {
+		var paths []string
+		for _, name := range names {
+			paths = append(paths, filepath.Join(root, name))
+		}
+		return paths
+	}
+
+	tests := []struct {
+		name string
+		root string
+		q    FindQuery
+		want []string
+	}{
+		{"按文件名", root, FindQuery{Name: "*.log", ExcludeDirs: []string{"node_modules"}}, join("app.log", "logs/a.log", "logs/old/b.log")},
+		{"限制深度", root, FindQuery{Name: "*.log", MaxDepth: 1}, join("app.log")},
+		{"只查找目录", root, FindQuery{Types: []FileType{FileTypeDir}}, join("logs", "logs/old", "node_modules")},
+		{"根路径是匹配的文件", filepath.Join(root, "app.log"), FindQuery{Name: "*.log"}, join("app.log")},
+		{"根路径是不匹配的文件", filepath.Join(root, "app.log"), FindQuery{Name: "*.txt"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FindPaths(context.Background(), tt.root, tt.q)
+			if err != nil {
+				t.Fatalf("FindPaths() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FindPaths() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// 与基于filepath.Walk的旧实现保持一致: 传入文件时返回文件本身
+func TestSearchFileInPathWithFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.tar.gz")
+	mustDo(t, os.WriteFile(path, nil, 0644))
+	got, err := SearchFileInPath(path, "*.tar.gz")
+	if err != nil || !reflect.DeepEqual(got, []string{path}) {
+		t.Errorf("SearchFileInPath() = %v, %v, want [%s]", got, err, path)
+	}
+}
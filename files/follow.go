@@ -0,0 +1,252 @@
+package files
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// 默认检查文件变化的间隔
+const DefaultFollowPollInterval = 250 * time.Millisecond
+
+// 跟踪文件的选项
+type FollowOptions struct {
+	// 开始跟踪前先输出已有的最后几行, 0表示只输出之后新增的内容, 负数表示从文件开头输出
+	TailLines int
+	// 单行最大字节数(不含换行符), 超过时停止跟踪并返回ErrLineTooLong; 0为DefaultMaxLineLength, 负数不限制
+	MaxLineLength int
+	// 保留行尾的换行符, 默认去掉
+	KeepNewline bool
+	// 检查文件增长、截断和轮转的间隔, 默认DefaultFollowPollInterval
+	PollInterval time.Duration
+}
+
+// 文件跟踪器
+type Follower struct {
+	lines chan Line
+	done  chan struct{}
+	err   error
+}
+
+// 新增的行, 跟踪结束后关闭
+func (f *Follower) Lines() <-chan Line {
+	return f.lines
+}
+
+// 等待跟踪结束, 返回导致结束的错误; ctx取消属于正常结束, 返回nil
+func (f *Follower) Wait() error {
+	<-f.done
+	return f.err
+}
+
+/*
+与tail -F相同的方式跟踪文件, 直到ctx取消
+1. 文件不存在时等待其被创建, 然后从头读取
+2. 文件被截断时从头读取
+3. 路径指向了新的文件(重命名后重新创建, 或软链接切换到新文件, 如logger包按时间分割日志)时, 先读完旧文件剩余的内容, 再从头读取新文件
+4. 行号和偏移在切换到新文件或截断后重新计数
+5. 文件末尾没有换行符的内容会等到换行符写入后再输出, 切换到新文件时作为最后一行输出
+*/
+func FollowFile(ctx context.Context, filePath string, opts FollowOptions) *Follower {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultFollowPollInterval
+	}
+	maxLen := opts.MaxLineLength
+	if maxLen == 0 {
+		maxLen = DefaultMaxLineLength
+	}
+	f := &Follower{lines: make(chan Line), done: make(chan struct{})}
+	ff := &fileFollower{ctx: ctx, path: filePath, opts: opts, maxLen: maxLen, out: f.lines, buf: make([]byte, 64*1024)}
+	go func() {
+		defer close(f.done)
+		defer close(f.lines)
+		err := ff.run()
+		if ff.file != nil {
+			ff.file.Close()
+		}
+		if err != nil && ctx.Err() == nil {
+			f.err = err
+		}
+	}()
+	return f
+}
+
+type fileFollower struct {
+	ctx           context.Context
+	path          string
+	opts          FollowOptions
+	maxLen        int
+	out           chan<- Line
+	file          *os.File
+	buf           []byte
+	offset        int64 // 已经读取到的位置
+	pending       []byte
+	pendingOffset int64 // pending在文件中的起始偏移
+	number        int64
+}
+
+func (ff *fileFollower) run() error {
+	if err := ff.open(true); err != nil {
+		return err
+	}
+	for {
+		if err := ff.readAvailable(); err != nil {
+			return err
+		}
+		fileInfo, err := ff.file.Stat()
+		if err != nil {
+			return err
+		}
+		pathInfo, err := os.Stat(ff.path)
+		switch {
+		case os.IsNotExist(err) || err == nil && !os.SameFile(fileInfo, pathInfo):
+			if err = ff.rotate(); err != nil {
+				return err
+			}
+			continue
+		case err != nil:
+			return err
+		case fileInfo.Size() < ff.offset:
+			if _, err = ff.file.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			ff.reset(0)
+			continue
+		}
+		if err = sleepContext(ff.ctx, ff.opts.PollInterval); err != nil {
+			return err
+		}
+	}
+}
+
+// 打开文件, 不存在时等待; 初次打开时按TailLines确定起始位置, 之后总是从头读取
+func (ff *fileFollower) open(initial bool) error {
+	for {
+		f, err := os.Open(ff.path)
+		if err == nil {
+			ff.file = f
+			break
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		initial = false
+		if err = sleepContext(ff.ctx, ff.opts.PollInterval); err != nil {
+			return err
+		}
+	}
+
+	var offset int64
+	if initial && ff.opts.TailLines >= 0 {
+		info, err := ff.file.Stat()
+		if err != nil {
+			return err
+		}
+		if offset, err = tailOffset(ff.file, info.Size(), ff.opts.TailLines); err != nil {
+			return err
+		}
+	}
+	if _, err := ff.file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	ff.reset(offset)
+	return nil
+}
+
+func (ff *fileFollower) reset(offset int64) {
+	ff.offset = offset
+	ff.pending = ff.pending[:0]
+	ff.pendingOffset = offset
+	ff.number = 0
+}
+
+// 读完旧文件剩余的内容后切换到新文件
+func (ff *fileFollower) rotate() error {
+	if err := ff.readAvailable(); err != nil {
+		return err
+	}
+	if len(ff.pending) > 0 {
+		if err := ff.send(ff.pending); err != nil {
+			return err
+		}
+	}
+	ff.file.Close()
+	ff.file = nil
+	return ff.open(false)
+}
+
+// 读取到文件末尾, 输出其中完整的行
+func (ff *fileFollower) readAvailable() error {
+	for {
+		if err := ff.ctx.Err(); err != nil {
+			return err
+		}
+		n, err := ff.file.Read(ff.buf)
+		if n > 0 {
+			ff.offset += int64(n)
+			ff.pending = append(ff.pending, ff.buf[:n]...)
+			if err := ff.emitLines(); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF || n == 0 && err == nil {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+func (ff *fileFollower) emitLines() error {
+	rest := ff.pending
+	for {
+		idx := bytes.IndexByte(rest, '\n')
+		if idx < 0 {
+			break
+		}
+		if err := ff.send(rest[:idx+1]); err != nil {
+			return err
+		}
+		rest = rest[idx+1:]
+	}
+	ff.pending = append(ff.pending[:0], rest...)
+	// 还没有读到换行符, 多留一个字节给可能的\r
+	if ff.maxLen > 0 && len(ff.pending) > ff.maxLen+1 {
+		return fmt.Errorf("第%d行 %w", ff.number+1, ErrLineTooLong)
+	}
+	return nil
+}
+
+func (ff *fileFollower) send(raw []byte) error {
+	content := trimNewline(raw)
+	if ff.maxLen > 0 && len(content) > ff.maxLen {
+		return fmt.Errorf("第%d行 %w", ff.number+1, ErrLineTooLong)
+	}
+	if ff.opts.KeepNewline {
+		content = raw
+	}
+	ff.number++
+	line := Line{Number: ff.number, Offset: ff.pendingOffset, Text: string(content)}
+	ff.pendingOffset += int64(len(raw))
+	select {
+	case ff.out <- line:
+		return nil
+	case <-ff.ctx.Done():
+		return ff.ctx.Err()
+	}
+}
+
+// 等待一段时间, ctx取消时提前返回
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,66 @@
+package files
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFollowFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	mustDo(t, os.WriteFile(path, []byte("a\nb\n"), 0644))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	follower := FollowFile(ctx, path, FollowOptions{TailLines: 1, PollInterval: 10 * time.Millisecond})
+
+	expect := func(want ...string) {
+		t.Helper()
+		for _, text := range want {
+			select {
+			case line, ok := <-follower.Lines():
+				if !ok {
+					t.Fatalf("跟踪提前结束: %v", follower.Wait())
+				}
+				if line.Text != text {
+					t.Fatalf("Lines() = %q, want %q", line.Text, text)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("等待 %q 超时", text)
+			}
+		}
+	}
+	appendFile := func(path, content string) {
+		t.Helper()
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		mustDo(t, err)
+		_, err = f.WriteString(content)
+		mustDo(t, err)
+		mustDo(t, f.Close())
+	}
+
+	expect("b")
+	appendFile(path, "c\n")
+	expect("c")
+
+	// 轮转: 先读完旧文件剩余的内容(包括没有换行符的最后一行), 再从头读取新文件
+	appendFile(path, "d")
+	mustDo(t, os.Rename(path, path+".1"))
+	appendFile(path, "e\n")
+	expect("d", "e")
+
+	// 截断后从头读取
+	mustDo(t, os.Truncate(path, 0))
+	time.Sleep(100 * time.Millisecond)
+	appendFile(path, "f\n")
+	expect("f")
+
+	cancel()
+	for range follower.Lines() {
+	}
+	if err := follower.Wait(); err != nil {
+		t.Errorf("Wait() = %v, want nil", err)
+	}
+}
@@ -0,0 +1,254 @@
+package files
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"regexp"
+	"runtime"
+	"sort"
+	"sync"
+	"unicode/utf8"
+)
+
+// 判断二进制文件时检查的字节数
+const binarySniffSize = 8000
+
+// 内容搜索的选项
+type GrepOptions struct {
+	// 按普通字符串匹配而不是正则表达式
+	Literal bool
+	// 忽略大小写
+	IgnoreCase bool
+	// 输出匹配行之前和之后的行数
+	Before int
+	After  int
+	// 单行最大字节数, 超过时该文件返回ErrLineTooLong并停止搜索; 0为DefaultMaxLineLength, 负数不限制
+	MaxLineLength int
+	// 目录下参与搜索的文件范围, 其中Types不生效, 总是只搜索普通文件
+	Files FindQuery
+	// 同时搜索的文件数量, 默认为CPU核数
+	Workers int
+}
+
+// 搜索结果, Err不为nil时表示搜索该文件失败, 其余字段无效
+type GrepResult struct {
+	Path   string   `json:"path"`
+	Line   int64    `json:"line"`   // 行号, 从1开始
+	Column int      `json:"column"` // 第一处匹配在该行中的字符位置, 从1开始
+	Text   string   `json:"text"`
+	Before []string `json:"before,omitempty"`
+	After  []string `json:"after,omitempty"`
+	Err    error    `json:"-"`
+}
+
+/*
+在文件或目录下的全部文件中搜索匹配pattern的行, 结果通过channel流式返回, 搜索结束或ctx取消后关闭
+1. 同一行有多处匹配时只返回一个结果, Column为第一处匹配的位置
+2. 开头8000字节中包含\0的文件视为二进制文件并跳过
+3. gzip压缩的文件(如轮转后的日志)会自动解压后搜索
+4. 同一文件的结果按行号顺序返回, 不同文件之间的顺序不固定
+*/
+func Grep(ctx context.Context, root, pattern string, opts GrepOptions) (<-chan GrepResult, error) {
+	if opts.Literal {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if opts.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.NumCPU()
+	}
+
+	paths := make(chan string)
+	out := make(chan GrepResult, 64)
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		query := opts.Files
+		query.Types = []FileType{FileTypeFile}
+		found, err := Find(ctx, root, query)
+		if err != nil {
+			return nil, err
+		}
+		go func() {
+			defer close(paths)
+			for result := range found {
+				if result.Err != nil {
+					sendGrepResult(ctx, out, GrepResult{Path: result.Path, Err: result.Err})
+					continue
+				}
+				select {
+				case paths <- result.Path:
+				case <-ctx.Done():
+				}
+			}
+		}()
+	} else {
+		go func() {
+			defer close(paths)
+			select {
+			case paths <- root:
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				if err := grepFile(ctx, path, re, opts, out); err != nil && ctx.Err() == nil {
+					sendGrepResult(ctx, out, GrepResult{Path: path, Err: err})
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out, nil
+}
+
+// 搜索并返回按文件路径和行号排序的全部结果, 搜索出错时继续搜索, 返回已找到的结果和第一个错误
+func GrepAll(ctx context.Context, root, pattern string, opts GrepOptions) ([]GrepResult, error) {
+	results, err := Grep(ctx, root, pattern, opts)
+	if err != nil {
+		return nil, err
+	}
+	var matches []GrepResult
+	var firstErr error
+	for result := range results {
+		if result.Err != nil {
+			if firstErr == nil {
+				firstErr = result.Err
+			}
+			continue
+		}
+		matches = append(matches, result)
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Path != matches[j].Path {
+			return matches[i].Path < matches[j].Path
+		}
+		return matches[i].Line < matches[j].Line
+	})
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+	return matches, firstErr
+}
+
+func sendGrepResult(ctx context.Context, out chan<- GrepResult, result GrepResult) bool {
+	select {
+	case out <- result:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// 打开文件, gzip文件返回解压后的内容; 二进制文件返回nil
+func openGrepReader(f *os.File) (io.Reader, error) {
+	br := bufio.NewReaderSize(f, 64*1024)
+	var r io.Reader = br
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		br = bufio.NewReaderSize(gz, 64*1024)
+		r = br
+	}
+	head, err := br.Peek(binarySniffSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	if bytes.IndexByte(head, 0) >= 0 {
+		return nil, nil
+	}
+	return r, nil
+}
+
+func grepFile(ctx context.Context, path string, re *regexp.Regexp, opts GrepOptions, out chan<- GrepResult) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r, err := openGrepReader(f)
+	if err != nil || r == nil {
+		return err
+	}
+
+	lr := NewLineReader(r, LineReaderOptions{MaxLineLength: opts.MaxLineLength})
+	var before []string
+	var waiting []*GrepResult // 还在等待后续上下文行的结果
+	flush := func(all bool) bool {
+		n := 0
+		for _, result := range waiting {
+			if !all && len(result.After) < opts.After {
+				waiting[n] = result
+				n++
+				continue
+			}
+			if !sendGrepResult(ctx, out, *result) {
+				return false
+			}
+		}
+		waiting = waiting[:n]
+		return true
+	}
+	for lr.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		line := lr.Bytes()
+		text := ""
+		for _, result := range waiting {
+			if len(result.After) < opts.After {
+				if text == "" {
+					text = string(line)
+				}
+				result.After = append(result.After, text)
+			}
+		}
+		if loc := re.FindIndex(line); loc != nil {
+			result := &GrepResult{
+				Path:   path,
+				Line:   lr.Line().Number,
+				Column: utf8.RuneCount(line[:loc[0]]) + 1,
+				Text:   string(line),
+			}
+			if len(before) > 0 {
+				result.Before = append([]string(nil), before...)
+			}
+			waiting = append(waiting, result)
+		}
+		if !flush(false) {
+			return ctx.Err()
+		}
+		if opts.Before > 0 {
+			if len(before) == opts.Before {
+				before = append(before[:0], before[1:]...)
+			}
+			before = append(before, string(line))
+		}
+	}
+	if !flush(true) {
+		return ctx.Err()
+	}
+	return lr.Err()
+}
@@ -0,0 +1,84 @@
+package files
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGrepAll(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"app.log":     "start\nERROR 连接失败\nretry\nerror again\nend\n",
+		"sub/app.txt": "a.b\naxb\n",
+		"bin.dat":     "error\x00binary",
+	})
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, err := zw.Write([]byte("old\nerror rotated\n"))
+	mustDo(t, err)
+	mustDo(t, zw.Close())
+	mustDo(t, os.WriteFile(filepath.Join(dir, "app.log.1.gz"), gz.Bytes(), 0644))
+
+	tests := []struct {
+		name    string
+		pattern string
+		opts    GrepOptions
+		want    []GrepResult
+	}{
+		{"忽略大小写并带上下文", "error", GrepOptions{IgnoreCase: true, Before: 1, After: 1, Files: FindQuery{Name: "app.log"}}, []GrepResult{
+			{Line: 2, Column: 1, Text: "ERROR 连接失败", Before: []string{"start"}, After: []string{"retry"}},
+			{Line: 4, Column: 1, Text: "error again", Before: []string{"retry"}, After: []string{"end"}},
+		}},
+		{"字符位置按字符计算", "失败", GrepOptions{Files: FindQuery{Name: "app.log"}}, []GrepResult{
+			{Line: 2, Column: 9, Text: "ERROR 连接失败"},
+		}},
+		{"普通字符串匹配", "a.b", GrepOptions{Literal: true}, []GrepResult{
+			{Path: "sub/app.txt", Line: 1, Column: 1, Text: "a.b"},
+		}},
+		{"跳过二进制文件并解压gzip", "^error", GrepOptions{}, []GrepResult{
+			{Path: "app.log", Line: 4, Column: 1, Text: "error again"},
+			{Path: "app.log.1.gz", Line: 2, Column: 1, Text: "error rotated"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GrepAll(context.Background(), dir, tt.pattern, tt.opts)
+			if err != nil {
+				t.Fatalf("GrepAll() error = %v", err)
+			}
+			for i := range tt.want {
+				if tt.want[i].Path == "" {
+					tt.want[i].Path = "app.log"
+				}
+				tt.want[i].Path = filepath.Join(dir, filepath.FromSlash(tt.want[i].Path))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GrepAll() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := GrepAll(context.Background(), dir, "(", GrepOptions{}); err == nil {
+		t.Errorf("GrepAll(无效的正则) error = nil, want error")
+	}
+}
+
+func TestGrepFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "long.log")
+	mustDo(t, os.WriteFile(path, []byte("match\n"+strings.Repeat("x", 100)+"\n"), 0644))
+	got, err := GrepAll(context.Background(), path, "match", GrepOptions{MaxLineLength: 10})
+	if !errors.Is(err, ErrLineTooLong) {
+		t.Errorf("GrepAll() error = %v, want %v", err, ErrLineTooLong)
+	}
+	// 出错之前找到的结果仍然返回
+	if len(got) != 1 || got[0].Path != path || got[0].Line != 1 {
+		t.Errorf("GrepAll() = %+v, want 第1行", got)
+	}
+}
@@ -0,0 +1,203 @@
+package files
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// 哈希算法名称
+type HashAlgorithm string
+
+const (
+	HashMD5        HashAlgorithm = "md5"
+	HashSHA1       HashAlgorithm = "sha1"
+	HashSHA256     HashAlgorithm = "sha256"
+	HashSHA512     HashAlgorithm = "sha512"
+	HashBLAKE2b256 HashAlgorithm = "blake2b-256"
+	HashBLAKE2b512 HashAlgorithm = "blake2b-512"
+	HashCRC32C     HashAlgorithm = "crc32c"
+)
+
+// 哈希计算结果, 算法名称 -> 十六进制摘要
+type HashResult map[HashAlgorithm]string
+
+var (
+	hashRegistryLock sync.RWMutex
+	hashRegistry     = map[HashAlgorithm]func() hash.Hash{
+		HashMD5:    md5.New,
+		HashSHA1:   sha1.New,
+		HashSHA256: sha256.New,
+		HashSHA512: sha512.New,
+		HashBLAKE2b256: func() hash.Hash {
+			// key为空时不会返回错误
+			h, _ := blake2b.New256(nil)
+			return h
+		},
+		HashBLAKE2b512: func() hash.Hash {
+			h, _ := blake2b.New512(nil)
+			return h
+		},
+		HashCRC32C: func() hash.Hash {
+			return crc32.New(crc32.MakeTable(crc32.Castagnoli))
+		},
+	}
+)
+
+// 注册自定义哈希算法, 已存在的同名算法会被覆盖
+func RegisterHashAlgorithm(algo HashAlgorithm, newFunc func() hash.Hash) {
+	hashRegistryLock.Lock()
+	defer hashRegistryLock.Unlock()
+	hashRegistry[algo] = newFunc
+}
+
+// 获取已注册的全部哈希算法名称, 按名称排序
+func HashAlgorithms() []HashAlgorithm {
+	hashRegistryLock.RLock()
+	defer hashRegistryLock.RUnlock()
+	algos := make([]HashAlgorithm, 0, len(hashRegistry))
+	for algo := range hashRegistry {
+		algos = append(algos, algo)
+	}
+	sort.Slice(algos, func(i, j int) bool {
+		return algos[i] < algos[j]
+	})
+	return algos
+}
+
+// 根据算法名称创建哈希实例, 未指定算法时默认使用SHA256
+func newHashers(algos []HashAlgorithm) (map[HashAlgorithm]hash.Hash, error) {
+	if len(algos) == 0 {
+		algos = []HashAlgorithm{HashSHA256}
+	}
+	hashRegistryLock.RLock()
+	defer hashRegistryLock.RUnlock()
+	hashers := make(map[HashAlgorithm]hash.Hash, len(algos))
+	for _, algo := range algos {
+		newFunc, ok := hashRegistry[algo]
+		if !ok {
+			return nil, fmt.Errorf("不支持的哈希算法: %s", algo)
+		}
+		hashers[algo] = newFunc()
+	}
+	return hashers, nil
+}
+
+func sumHashers(hashers map[HashAlgorithm]hash.Hash) HashResult {
+	result := make(HashResult, len(hashers))
+	for algo, h := range hashers {
+		result[algo] = hex.EncodeToString(h.Sum(nil))
+	}
+	return result
+}
+
+// 计算io.Reader内容的摘要, 多个算法只读取一遍数据
+func CalcReaderHash(reader io.Reader, algos ...HashAlgorithm) (HashResult, error) {
+	hashers, err := newHashers(algos)
+	if err != nil {
+		return nil, err
+	}
+	writers := make([]io.Writer, 0, len(hashers))
+	for _, h := range hashers {
+		writers = append(writers, h)
+	}
+	if _, err = io.Copy(io.MultiWriter(writers...), reader); err != nil {
+		return nil, err
+	}
+	return sumHashers(hashers), nil
+}
+
+// 计算文件的摘要, 多个算法只读取一遍文件
+func CalcFileHash(filePath string, algos ...HashAlgorithm) (HashResult, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("计算文件摘要失败, 无法打开文件! 文件路径: %s 错误信息: %v", filePath, err)
+	}
+	defer f.Close()
+	result, err := CalcReaderHash(f, algos...)
+	if err != nil {
+		return nil, fmt.Errorf("计算文件摘要失败! 文件路径: %s 错误信息: %v", filePath, err)
+	}
+	return result, nil
+}
+
+/*
+计算目录树的摘要
+1. 遍历目录下的普通文件和软链接, 按照以/分隔的相对路径排序
+2. 逐个计算文件摘要, 软链接使用链接目标字符串计算摘要
+3. 将 "<摘要>  <相对路径>\n" 依次写入目录摘要, 格式与sha256sum输出一致
+空目录不参与计算, 因此只要文件内容和相对路径相同, 目录摘要就相同
+*/
+func CalcDirHash(dirPath string, algos ...HashAlgorithm) (HashResult, error) {
+	treeHashers, err := newHashers(algos)
+	if err != nil {
+		return nil, err
+	}
+	relPaths, err := listTreeEntries(dirPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, relPath := range relPaths {
+		fileResult, err := calcTreeEntryHash(filepath.Join(dirPath, filepath.FromSlash(relPath)), algos)
+		if err != nil {
+			return nil, err
+		}
+		for algo, h := range treeHashers {
+			if _, err = fmt.Fprintf(h, "%s  %s\n", fileResult[algo], relPath); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return sumHashers(treeHashers), nil
+}
+
+// 获取目录下所有普通文件和软链接的相对路径(以/分隔), 结果已排序
+func listTreeEntries(dirPath string) ([]string, error) {
+	var relPaths []string
+	err := filepath.WalkDir(dirPath, func(path string, entry fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if entry.IsDir() || !(entry.Type().IsRegular() || entry.Type()&fs.ModeSymlink != 0) {
+			return nil
+		}
+		relPath, err := filepath.Rel(dirPath, path)
+		if err != nil {
+			return err
+		}
+		relPaths = append(relPaths, filepath.ToSlash(relPath))
+		return nil
+	})
+	sort.Strings(relPaths)
+	return relPaths, err
+}
+
+// 计算目录树中单个条目的摘要, 软链接不跟随, 使用链接目标计算
+func calcTreeEntryHash(path string, algos []HashAlgorithm) (HashResult, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		link, err := os.Readlink(path)
+		if err != nil {
+			return nil, err
+		}
+		return CalcReaderHash(strings.NewReader(link), algos...)
+	}
+	return CalcFileHash(path, algos...)
+}
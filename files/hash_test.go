@@ -0,0 +1,84 @@
+package files
+
+import (
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCalcReaderHash(t *testing.T) {
+	tests := []struct {
+		name    string
+		algos   []HashAlgorithm
+		want    HashResult
+		wantErr bool
+	}{
+		{"默认sha256", nil, HashResult{HashSHA256: "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"}, false},
+		{"多个算法", []HashAlgorithm{HashMD5, HashSHA1, HashBLAKE2b256, HashCRC32C}, HashResult{
+			HashMD5:        "900150983cd24fb0d6963f7d28e17f72",
+			HashSHA1:       "a9993e364706816aba3e25717850c26c9cd0d89d",
+			HashBLAKE2b256: "bddd813c634239723171ef3fee98579b94964e3bb1cb3e427262c8c068d52319",
+			HashCRC32C:     "364b3fb7",
+		}, false},
+		{"不支持的算法", []HashAlgorithm{"sm3"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CalcReaderHash(strings.NewReader("abc"), tt.algos...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CalcReaderHash() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CalcReaderHash() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterHashAlgorithm(t *testing.T) {
+	const algo HashAlgorithm = "test-sha224"
+	RegisterHashAlgorithm(algo, sha256.New224)
+	got, err := CalcReaderHash(strings.NewReader("abc"), algo)
+	mustDo(t, err)
+	if want := "23097d223405d8228642a477bda255b32aadbce4bda0b3f7e36c9da7"; got[algo] != want {
+		t.Errorf("CalcReaderHash(%s) = %s, want %s", algo, got[algo], want)
+	}
+	found := false
+	for _, a := range HashAlgorithms() {
+		found = found || a == algo
+	}
+	if !found {
+		t.Errorf("HashAlgorithms() = %v, want 包含 %s", HashAlgorithms(), algo)
+	}
+}
+
+func TestCalcDirHash(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	writeTree(t, src, map[string]string{"a.txt": "1"})
+	mustDo(t, os.MkdirAll(filepath.Join(src, "empty"), 0755))
+	mustDo(t, os.Symlink("a.txt", filepath.Join(src, "link")))
+
+	got, err := CalcDirHash(src)
+	if err != nil {
+		t.Fatalf("CalcDirHash() error = %v", err)
+	}
+	// sha256("<sha256(1)>  a.txt\n<sha256(a.txt)>  link\n"), 空目录不参与计算, 软链接按链接目标计算
+	if want := "e0529e6e6edb791f4dcab6b85b70c51525663d3393ac1dd5818e35231dfe8fbb"; got[HashSHA256] != want {
+		t.Errorf("CalcDirHash() = %s, want %s", got[HashSHA256], want)
+	}
+
+	// 目录位置不影响摘要, 文件内容变化时摘要变化
+	moved := filepath.Join(dir, "moved")
+	mustDo(t, os.Rename(src, moved))
+	if again, err := CalcDirHash(moved); err != nil || !reflect.DeepEqual(again, got) {
+		t.Errorf("移动后 CalcDirHash() = %v, %v, want %v", again, err, got)
+	}
+	mustDo(t, os.WriteFile(filepath.Join(moved, "a.txt"), []byte("2"), 0644))
+	if changed, err := CalcDirHash(moved); err != nil || reflect.DeepEqual(changed, got) {
+		t.Errorf("修改后 CalcDirHash() = %v, %v, 摘要应该变化", changed, err)
+	}
+}
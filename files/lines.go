@@ -0,0 +1,252 @@
+package files
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// 默认的单行最大长度
+const DefaultMaxLineLength = 1024 * 1024
+
+var ErrLineTooLong = errors.New("行长度超过限制")
+
+// 逐行读取的选项
+type LineReaderOptions struct {
+	// 单行最大字节数(不含换行符), 超过时返回ErrLineTooLong; 0为DefaultMaxLineLength, 负数不限制
+	MaxLineLength int
+	// 保留行尾的换行符(\n或\r\n), 默认去掉
+	KeepNewline bool
+	// 从该字节偏移处开始读取, 偏移不在行首时第一行只包含该行剩余的部分
+	Offset int64
+}
+
+// 一行内容
+type Line struct {
+	Number int64  `json:"number"` // 行号, 从1开始; 指定Offset时从Offset处开始计数
+	Offset int64  `json:"offset"` // 行首在文件中的字节偏移
+	Text   string `json:"text"`
+}
+
+/*
+流式逐行读取, 用法与bufio.Scanner类似
+
+	lr := files.NewLineReader(f, files.LineReaderOptions{})
+	for lr.Next() {
+		fmt.Println(lr.Text())
+	}
+	if err := lr.Err(); err != nil {
+		...
+	}
+*/
+type LineReader struct {
+	r          *bufio.Reader
+	closer     io.Closer
+	opts       LineReaderOptions
+	maxLen     int
+	buf        []byte
+	line       []byte
+	number     int64
+	offset     int64 // 下一行的起始偏移
+	lineOffset int64
+	err        error
+}
+
+// 从r中逐行读取, r实现了io.Seeker时通过Seek跳到Offset, 否则丢弃Offset之前的数据
+func NewLineReader(r io.Reader, opts LineReaderOptions) *LineReader {
+	lr := &LineReader{r: bufio.NewReaderSize(r, 64*1024), opts: opts, maxLen: opts.MaxLineLength, offset: opts.Offset}
+	if lr.maxLen == 0 {
+		lr.maxLen = DefaultMaxLineLength
+	}
+	if opts.Offset > 0 {
+		if seeker, ok := r.(io.Seeker); ok {
+			_, lr.err = seeker.Seek(opts.Offset, io.SeekStart)
+		} else {
+			_, lr.err = io.CopyN(io.Discard, r, opts.Offset)
+		}
+	}
+	return lr
+}
+
+// 打开文件逐行读取, 使用完毕后需要调用Close
+func OpenLineReader(filePath string, opts LineReaderOptions) (*LineReader, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	lr := NewLineReader(f, opts)
+	if lr.err != nil {
+		f.Close()
+		return nil, lr.err
+	}
+	lr.closer = f
+	return lr, nil
+}
+
+// 读取下一行, 读到文件末尾或出错时返回false
+func (lr *LineReader) Next() bool {
+	if lr.err != nil {
+		return false
+	}
+	lr.buf = lr.buf[:0]
+	for {
+		chunk, err := lr.r.ReadSlice('\n')
+		lr.buf = append(lr.buf, chunk...)
+		if err == bufio.ErrBufferFull {
+			// 还没有读到换行符, 多留一个字节给可能的\r
+			if lr.maxLen > 0 && len(lr.buf) > lr.maxLen+1 {
+				lr.err = fmt.Errorf("第%d行 %w", lr.number+1, ErrLineTooLong)
+				return false
+			}
+			continue
+		}
+		if err == io.EOF {
+			if len(lr.buf) == 0 {
+				return false
+			}
+			break
+		}
+		if err != nil {
+			lr.err = err
+			return false
+		}
+		break
+	}
+	content := trimNewline(lr.buf)
+	if lr.maxLen > 0 && len(content) > lr.maxLen {
+		lr.err = fmt.Errorf("第%d行 %w", lr.number+1, ErrLineTooLong)
+		return false
+	}
+	lr.number++
+	lr.lineOffset = lr.offset
+	lr.offset += int64(len(lr.buf))
+	if lr.opts.KeepNewline {
+		lr.line = lr.buf
+	} else {
+		lr.line = content
+	}
+	return true
+}
+
+// 去掉行尾的\n或\r\n
+func trimNewline(line []byte) []byte {
+	if bytes.HasSuffix(line, []byte("\n")) {
+		line = line[:len(line)-1]
+		if bytes.HasSuffix(line, []byte("\r")) {
+			line = line[:len(line)-1]
+		}
+	}
+	return line
+}
+
+// 当前行的内容, 在下一次调用Next之前有效
+func (lr *LineReader) Bytes() []byte {
+	return lr.line
+}
+
+// 当前行的内容
+func (lr *LineReader) Text() string {
+	return string(lr.line)
+}
+
+// 当前行
+func (lr *LineReader) Line() Line {
+	return Line{Number: lr.number, Offset: lr.lineOffset, Text: string(lr.line)}
+}
+
+// 下一行在文件中的起始偏移, 可以保存下来作为下次读取的Offset
+func (lr *LineReader) Offset() int64 {
+	return lr.offset
+}
+
+// 读取过程中遇到的错误, 正常读到文件末尾时为nil
+func (lr *LineReader) Err() error {
+	return lr.err
+}
+
+// 关闭OpenLineReader打开的文件
+func (lr *LineReader) Close() error {
+	if lr.closer == nil {
+		return nil
+	}
+	return lr.closer.Close()
+}
+
+// 逐行读取文件并回调, 回调返回StopWalk时提前结束并返回nil
+func ReadLines(filePath string, opts LineReaderOptions, fn func(line Line) error) error {
+	lr, err := OpenLineReader(filePath, opts)
+	if err != nil {
+		return err
+	}
+	defer lr.Close()
+	for lr.Next() {
+		if err := fn(lr.Line()); err == StopWalk {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	return lr.Err()
+}
+
+// 读取文件的最后n行, 从文件末尾向前查找, 不会读取整个文件
+func TailLines(filePath string, n int, opts LineReaderOptions) ([]string, error) {
+	offset, err := TailOffset(filePath, n)
+	if err != nil {
+		return nil, err
+	}
+	opts.Offset = offset
+	var lines []string
+	err = ReadLines(filePath, opts, func(line Line) error {
+		lines = append(lines, line.Text)
+		return nil
+	})
+	return lines, err
+}
+
+// 获取文件最后n行的起始字节偏移, 文件不足n行时返回0
+func TailOffset(filePath string, n int) (int64, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return tailOffset(f, info.Size(), n)
+}
+
+func tailOffset(r io.ReaderAt, size int64, n int) (int64, error) {
+	if n <= 0 {
+		return size, nil
+	}
+	buf := make([]byte, 64*1024)
+	count := 0
+	for end := size; end > 0; {
+		start := end - int64(len(buf))
+		if start < 0 {
+			start = 0
+		}
+		chunk := buf[:end-start]
+		if _, err := r.ReadAt(chunk, start); err != nil && err != io.EOF {
+			return 0, err
+		}
+		for i := len(chunk) - 1; i >= 0; i-- {
+			// 文件末尾的换行符属于最后一行
+			if chunk[i] != '\n' || start+int64(i) == size-1 {
+				continue
+			}
+			count++
+			if count == n {
+				return start + int64(i) + 1, nil
+			}
+		}
+		end = start
+	}
+	return 0, nil
+}
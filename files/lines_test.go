@@ -0,0 +1,96 @@
+package files
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLineReader(t *testing.T) {
+	const content = "first\r\nsecond\n\nlast"
+	tests := []struct {
+		name    string
+		opts    LineReaderOptions
+		want    []Line
+		wantErr error
+	}{
+		{"默认去掉换行符", LineReaderOptions{}, []Line{{1, 0, "first"}, {2, 7, "second"}, {3, 14, ""}, {4, 15, "last"}}, nil},
+		{"保留换行符", LineReaderOptions{KeepNewline: true}, []Line{{1, 0, "first\r\n"}, {2, 7, "second\n"}, {3, 14, "\n"}, {4, 15, "last"}}, nil},
+		{"从行中间开始", LineReaderOptions{Offset: 9}, []Line{{1, 9, "cond"}, {2, 14, ""}, {3, 15, "last"}}, nil},
+		{"行长度超过限制", LineReaderOptions{MaxLineLength: 5}, []Line{{1, 0, "first"}}, ErrLineTooLong},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// strings.Reader实现了io.Seeker, 再用不支持Seek的Reader各测一遍
+			readers := []io.Reader{strings.NewReader(content), onlyReader{strings.NewReader(content)}}
+			for _, r := range readers {
+				lr := NewLineReader(r, tt.opts)
+				var got []Line
+				for lr.Next() {
+					got = append(got, lr.Line())
+				}
+				if !errors.Is(lr.Err(), tt.wantErr) || (lr.Err() != nil) != (tt.wantErr != nil) {
+					t.Fatalf("Err() = %v, want %v", lr.Err(), tt.wantErr)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("Next() = %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+type onlyReader struct {
+	r *strings.Reader
+}
+
+func (r onlyReader) Read(p []byte) (int, error) {
+	return r.r.Read(p)
+}
+
+func TestTailLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	var b strings.Builder
+	for i := 0; i < 20000; i++ {
+		b.WriteString(strings.Repeat("x", i%7))
+		b.WriteString("\n")
+	}
+	b.WriteString("last")
+	mustDo(t, os.WriteFile(path, []byte(b.String()), 0644))
+	all := strings.Split(b.String(), "\n")
+
+	tests := []struct {
+		name string
+		n    int
+		want []string
+	}{
+		{"最后1行", 1, []string{"last"}},
+		{"跨越多个块", 15000, all[len(all)-15000:]},
+		{"不足n行", 30000, all},
+		{"0行", 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TailLines(path, tt.n, LineReaderOptions{})
+			if err != nil {
+				t.Fatalf("TailLines() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TailLines() 返回%d行, want %d行", len(got), len(tt.want))
+			}
+		})
+	}
+
+	// 文件末尾的换行符属于最后一行
+	mustDo(t, os.WriteFile(path, []byte("a\nb\n"), 0644))
+	if got, err := TailLines(path, 1, LineReaderOptions{}); err != nil || !reflect.DeepEqual(got, []string{"b"}) {
+		t.Errorf("TailLines() = %q, %v, want [b]", got, err)
+	}
+	if got, err := ReadFileAsLines(path); err != nil || !reflect.DeepEqual(got, []string{"a\n", "b\n"}) {
+		t.Errorf("ReadFileAsLines() = %q, %v, want [a\\n b\\n]", got, err)
+	}
+}
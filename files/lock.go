@@ -0,0 +1,121 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+)
+
+var (
+	ErrLocked          = errors.New("文件已被其他进程锁定")
+	ErrLockTimeout     = errors.New("等待文件锁超时")
+	ErrLockUnsupported = errors.New("当前操作系统不支持文件锁")
+)
+
+// 阻塞等待文件锁时重试的最大间隔
+const maxLockRetryInterval = 100 * time.Millisecond
+
+// 文件锁选项
+type LockOptions struct {
+	// 共享锁(读锁), 默认为排他锁(写锁)
+	Shared bool
+	// 阻塞等待的超时时间, 0表示一直等待直到ctx取消; 仅LockFile
+	Timeout time.Duration
+	// 使用fcntl的OFD锁代替flock, 在NFS上也能生效
+	UseFcntl bool
+}
+
+/*
+进程间的建议性文件锁, 只对同样使用文件锁的进程有效
+1. 锁与打开的文件绑定, 进程退出时由内核自动释放, 不会残留
+2. 锁文件不存在时自动创建, 解锁后不会删除
+3. 持有锁时可以删除锁文件(如PIDFile.Remove), 其他进程加锁后会确认锁住的仍是路径上的文件, 否则重新打开并加锁
+*/
+type FileLock struct {
+	path string
+	file *os.File
+	opts LockOptions
+}
+
+// 尝试获取文件锁, 已被其他进程锁定时立即返回ErrLocked
+func TryLockFile(path string, opts LockOptions) (*FileLock, error) {
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+		if err != nil {
+			return nil, err
+		}
+		if err = lockFile(f, opts); err != nil {
+			f.Close()
+			return nil, err
+		}
+		// 打开之后、加锁之前锁文件可能被持有者删除或替换, 此时锁住的文件已经不在路径上, 需要重试
+		if same, err := isLockedFileCurrent(f, path); err != nil || !same {
+			unlockFile(f, opts)
+			f.Close()
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		return &FileLock{path: path, file: f, opts: opts}, nil
+	}
+}
+
+// 判断已打开的文件是否仍是路径上的文件
+func isLockedFileCurrent(f *os.File, path string) (bool, error) {
+	opened, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	current, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return os.SameFile(opened, current), nil
+}
+
+// 阻塞获取文件锁, 直到成功、超时(ErrLockTimeout)或ctx取消; 等待期间定时重试而不是阻塞在系统调用中
+func LockFile(ctx context.Context, path string, opts LockOptions) (*FileLock, error) {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	interval := 5 * time.Millisecond
+	for {
+		lock, err := TryLockFile(path, opts)
+		if !errors.Is(err, ErrLocked) {
+			return lock, err
+		}
+		if err = sleepContext(ctx, interval); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) && opts.Timeout > 0 {
+				return nil, ErrLockTimeout
+			}
+			return nil, err
+		}
+		if interval *= 2; interval > maxLockRetryInterval {
+			interval = maxLockRetryInterval
+		}
+	}
+}
+
+// 锁文件的路径
+func (l *FileLock) Path() string {
+	return l.path
+}
+
+// 释放文件锁并关闭文件
+func (l *FileLock) Unlock() error {
+	if l.file == nil {
+		return nil
+	}
+	err := unlockFile(l.file, l.opts)
+	if closeErr := l.file.Close(); err == nil {
+		err = closeErr
+	}
+	l.file = nil
+	return err
+}
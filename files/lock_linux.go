@@ -0,0 +1,41 @@
+package files
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// 非阻塞加锁, 已被锁定时返回ErrLocked
+func lockFile(f *os.File, opts LockOptions) error {
+	var err error
+	if opts.UseFcntl {
+		lockType := int16(unix.F_WRLCK)
+		if opts.Shared {
+			lockType = unix.F_RDLCK
+		}
+		// 锁住整个文件, Len为0表示一直到文件末尾
+		err = unix.FcntlFlock(f.Fd(), unix.F_OFD_SETLK, &unix.Flock_t{Type: lockType, Whence: 0})
+	} else {
+		how := unix.LOCK_EX
+		if opts.Shared {
+			how = unix.LOCK_SH
+		}
+		err = unix.Flock(int(f.Fd()), how|unix.LOCK_NB)
+	}
+	if errors.Is(err, unix.EWOULDBLOCK) || errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EACCES) {
+		return ErrLocked
+	}
+	if err != nil {
+		return &os.PathError{Op: "lock", Path: f.Name(), Err: err}
+	}
+	return nil
+}
+
+func unlockFile(f *os.File, opts LockOptions) error {
+	if opts.UseFcntl {
+		return unix.FcntlFlock(f.Fd(), unix.F_OFD_SETLK, &unix.Flock_t{Type: unix.F_UNLCK, Whence: 0})
+	}
+	return unix.Flock(int(f.Fd()), unix.LOCK_UN)
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package files
+
+import "os"
+
+func lockFile(f *os.File, opts LockOptions) error {
+	return ErrLockUnsupported
+}
+
+func unlockFile(f *os.File, opts LockOptions) error {
+	return ErrLockUnsupported
+}
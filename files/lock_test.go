@@ -0,0 +1,108 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTryLockFile(t *testing.T) {
+	for _, opts := range []LockOptions{{}, {UseFcntl: true}} {
+		path := filepath.Join(t.TempDir(), "app.lock")
+		lock, err := TryLockFile(path, opts)
+		if err != nil {
+			t.Fatalf("TryLockFile(%+v) error = %v", opts, err)
+		}
+		// flock和OFD锁都与打开的文件绑定, 同一进程内再次打开也会冲突
+		if _, err = TryLockFile(path, opts); !errors.Is(err, ErrLocked) {
+			t.Errorf("重复加锁 error = %v, want ErrLocked", err)
+		}
+		if _, err = TryLockFile(path, LockOptions{Shared: true, UseFcntl: opts.UseFcntl}); !errors.Is(err, ErrLocked) {
+			t.Errorf("排他锁期间加共享锁 error = %v, want ErrLocked", err)
+		}
+		mustDo(t, lock.Unlock())
+
+		shared := LockOptions{Shared: true, UseFcntl: opts.UseFcntl}
+		first, err := TryLockFile(path, shared)
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := TryLockFile(path, shared)
+		if err != nil {
+			t.Errorf("共享锁之间不应冲突: %v", err)
+		} else {
+			second.Unlock()
+		}
+		first.Unlock()
+	}
+}
+
+func TestLockFileTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.lock")
+	lock, err := TryLockFile(path, LockOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Unlock()
+	if _, err = LockFile(context.Background(), path, LockOptions{Timeout: 50 * time.Millisecond}); !errors.Is(err, ErrLockTimeout) {
+		t.Errorf("LockFile() error = %v, want ErrLockTimeout", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = LockFile(ctx, path, LockOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("LockFile() error = %v, want context.Canceled", err)
+	}
+}
+
+// 持有者删除锁文件后, 等待中的进程不能锁住已经被删除的文件
+func TestLockFileAfterRemove(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.lock")
+	holder, err := TryLockFile(path, LockOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waiter := make(chan *FileLock)
+	go func() {
+		lock, err := LockFile(context.Background(), path, LockOptions{Timeout: 5 * time.Second})
+		if err != nil {
+			t.Error(err)
+		}
+		waiter <- lock
+	}()
+	time.Sleep(20 * time.Millisecond)
+	mustDo(t, os.Remove(path))
+	mustDo(t, holder.Unlock())
+
+	lock := <-waiter
+	if lock == nil {
+		return
+	}
+	defer lock.Unlock()
+	if _, err = TryLockFile(path, LockOptions{}); !errors.Is(err, ErrLocked) {
+		t.Errorf("等待者获得锁后, 路径上的锁文件仍能被锁定: %v", err)
+	}
+}
+
+// 打开锁文件之后文件被删除或替换时, 锁住的文件不再是路径上的文件
+func TestIsLockedFileCurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.lock")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if same, err := isLockedFileCurrent(f, path); err != nil || !same {
+		t.Errorf("isLockedFileCurrent() = %v, %v, want true", same, err)
+	}
+	mustDo(t, os.Remove(path))
+	if same, err := isLockedFileCurrent(f, path); err != nil || same {
+		t.Errorf("删除后 isLockedFileCurrent() = %v, %v, want false", same, err)
+	}
+	mustDo(t, os.WriteFile(path, nil, 0644))
+	if same, err := isLockedFileCurrent(f, path); err != nil || same {
+		t.Errorf("替换后 isLockedFileCurrent() = %v, %v, want false", same, err)
+	}
+}
@@ -0,0 +1,200 @@
+package files
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// 校验清单中的一条记录
+type ManifestEntry struct {
+	Digest string `json:"digest"`
+	Path   string `json:"path"` // 相对于目录的路径, 以/分隔
+}
+
+// 目录校验结果
+type ManifestVerifyResult struct {
+	Missing  []string `json:"missing"`  // 清单中有, 目录中不存在的文件
+	Extra    []string `json:"extra"`    // 目录中有, 清单中没有记录的文件
+	Modified []string `json:"modified"` // 摘要与清单不一致的文件
+}
+
+// 目录与清单完全一致时返回true
+func (r ManifestVerifyResult) OK() bool {
+	return len(r.Missing) == 0 && len(r.Extra) == 0 && len(r.Modified) == 0
+}
+
+/*
+生成目录的校验清单, 格式与sha256sum等工具的输出一致, 可以在目录下直接执行 sha256sum -c 校验
+1. 清单中的路径为相对于dirPath的路径, 按路径排序
+2. 如果清单文件位于dirPath下, 清单文件本身不会被记录
+3. 只记录普通文件, 软链接不跟随也不记录, 避免悬空链接或指向目录的链接导致失败
+*/
+func GenChecksumManifest(dirPath, manifestPath string, algo HashAlgorithm) error {
+	entries, err := calcManifestEntries(dirPath, manifestPath, algo)
+	if err != nil {
+		return err
+	}
+	f, err := NewAtomicFile(manifestPath, AtomicWriteOptions{KeepMode: true})
+	if err != nil {
+		return fmt.Errorf("创建校验清单失败! 文件路径: %s 错误信息: %v", manifestPath, err)
+	}
+	defer f.Abort()
+	if err = WriteChecksumManifest(f, entries); err != nil {
+		return err
+	}
+	return f.Commit()
+}
+
+// 将校验记录按sha256sum的格式写入writer, 含有反斜杠或换行符的路径按GNU coreutils的规则转义
+func WriteChecksumManifest(w io.Writer, entries []ManifestEntry) error {
+	bw := bufio.NewWriter(w)
+	for _, entry := range entries {
+		line := entry.Digest + "  " + entry.Path
+		if strings.ContainsAny(entry.Path, "\\\n") {
+			line = "\\" + entry.Digest + "  " + escapeManifestPath(entry.Path)
+		}
+		if _, err := bw.WriteString(line + "\n"); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// 解析sha256sum格式的校验清单, 兼容二进制模式的 "*" 标记和转义路径
+func ParseChecksumManifest(r io.Reader) ([]ManifestEntry, error) {
+	var entries []ManifestEntry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		escaped := strings.HasPrefix(line, "\\")
+		if escaped {
+			line = line[1:]
+		}
+		digest, rest, found := strings.Cut(line, " ")
+		if !found || digest == "" || rest == "" || (rest[0] != ' ' && rest[0] != '*') {
+			return entries, fmt.Errorf("校验清单格式错误! 第%d行: %s", lineNo, scanner.Text())
+		}
+		path := rest[1:]
+		if escaped {
+			path = unescapeManifestPath(path)
+		}
+		entries = append(entries, ManifestEntry{Digest: strings.ToLower(digest), Path: path})
+	}
+	return entries, scanner.Err()
+}
+
+/*
+根据校验清单检查目录
+Missing、Extra、Modified分别记录缺失、多余、被修改的文件, 均为相对路径并已排序
+只有读取清单或文件失败时才返回error, 校验不一致通过结果体现
+*/
+func VerifyChecksumManifest(dirPath, manifestPath string, algo HashAlgorithm) (ManifestVerifyResult, error) {
+	result := ManifestVerifyResult{}
+	f, err := os.Open(manifestPath)
+	if err != nil {
+		return result, fmt.Errorf("打开校验清单失败! 文件路径: %s 错误信息: %v", manifestPath, err)
+	}
+	defer f.Close()
+	expected, err := ParseChecksumManifest(f)
+	if err != nil {
+		return result, err
+	}
+	actual, err := calcManifestEntries(dirPath, manifestPath, algo)
+	if err != nil {
+		return result, err
+	}
+
+	actualDigests := make(map[string]string, len(actual))
+	for _, entry := range actual {
+		actualDigests[entry.Path] = entry.Digest
+	}
+	expectedPaths := make(map[string]bool, len(expected))
+	for _, entry := range expected {
+		path := strings.TrimPrefix(filepath.ToSlash(filepath.Clean(filepath.FromSlash(entry.Path))), "./")
+		expectedPaths[path] = true
+		digest, ok := actualDigests[path]
+		switch {
+		case !ok:
+			result.Missing = append(result.Missing, path)
+		case digest != entry.Digest:
+			result.Modified = append(result.Modified, path)
+		}
+	}
+	for _, entry := range actual {
+		if !expectedPaths[entry.Path] {
+			result.Extra = append(result.Extra, entry.Path)
+		}
+	}
+	sort.Strings(result.Missing)
+	sort.Strings(result.Modified)
+	return result, nil
+}
+
+// 计算目录下所有普通文件的校验记录, 排除清单文件本身和软链接; 生成和校验使用相同的规则
+func calcManifestEntries(dirPath, manifestPath string, algo HashAlgorithm) ([]ManifestEntry, error) {
+	var entries []ManifestEntry
+	if algo == "" {
+		algo = HashSHA256
+	}
+	relPaths, err := listTreeEntries(dirPath)
+	if err != nil {
+		return entries, err
+	}
+	absManifest, err := filepath.Abs(manifestPath)
+	if err != nil {
+		return entries, err
+	}
+	for _, relPath := range relPaths {
+		path := filepath.Join(dirPath, filepath.FromSlash(relPath))
+		if absPath, err := filepath.Abs(path); err == nil && absPath == absManifest {
+			continue
+		}
+		if info, err := os.Lstat(path); err != nil {
+			return entries, err
+		} else if info.Mode()&os.ModeSymlink != 0 {
+			continue
+		}
+		result, err := CalcFileHash(path, algo)
+		if err != nil {
+			return entries, err
+		}
+		entries = append(entries, ManifestEntry{Digest: result[algo], Path: relPath})
+	}
+	return entries, nil
+}
+
+func escapeManifestPath(path string) string {
+	path = strings.ReplaceAll(path, "\\", "\\\\")
+	return strings.ReplaceAll(path, "\n", "\\n")
+}
+
+func unescapeManifestPath(path string) string {
+	var sb strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+1 < len(path) {
+			switch path[i+1] {
+			case '\\':
+				sb.WriteByte('\\')
+				i++
+				continue
+			case 'n':
+				sb.WriteByte('\n')
+				i++
+				continue
+			}
+		}
+		sb.WriteByte(path[i])
+	}
+	return sb.String()
+}
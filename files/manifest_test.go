@@ -0,0 +1,65 @@
+package files
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestChecksumManifest(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "app")
+	manifest := filepath.Join(root, "SHA256SUMS")
+	mustDo(t, os.MkdirAll(filepath.Join(root, "conf"), 0755))
+	mustDo(t, os.WriteFile(filepath.Join(root, "bin"), []byte("binary"), 0755))
+	mustDo(t, os.WriteFile(filepath.Join(root, "conf", "app.yml"), []byte("port: 80"), 0644))
+	mustDo(t, os.WriteFile(filepath.Join(root, "conf", "log.yml"), []byte("level: info"), 0644))
+	// 软链接不记录: 悬空链接和指向目录的链接都不能导致失败
+	mustDo(t, os.Symlink("not-exist", filepath.Join(root, "dangling")))
+	mustDo(t, os.Symlink("conf", filepath.Join(root, "conf-link")))
+
+	if err := GenChecksumManifest(root, manifest, HashSHA256); err != nil {
+		t.Fatalf("GenChecksumManifest() error = %v", err)
+	}
+	f, err := os.Open(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := ParseChecksumManifest(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, entry := range entries {
+		paths = append(paths, entry.Path)
+	}
+	if want := []string{"bin", "conf/app.yml", "conf/log.yml"}; !reflect.DeepEqual(paths, want) {
+		t.Fatalf("清单中的路径 = %v, want %v", paths, want)
+	}
+
+	result, err := VerifyChecksumManifest(root, manifest, HashSHA256)
+	if err != nil || !result.OK() {
+		t.Fatalf("VerifyChecksumManifest() = %+v, %v, want OK", result, err)
+	}
+
+	mustDo(t, os.WriteFile(filepath.Join(root, "conf", "app.yml"), []byte("port: 8080"), 0644))
+	mustDo(t, os.Remove(filepath.Join(root, "conf", "log.yml")))
+	mustDo(t, os.WriteFile(filepath.Join(root, "extra"), []byte("new"), 0644))
+	// 普通文件被替换为软链接视为缺失
+	mustDo(t, os.Remove(filepath.Join(root, "bin")))
+	mustDo(t, os.Symlink("extra", filepath.Join(root, "bin")))
+	result, err = VerifyChecksumManifest(root, manifest, HashSHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ManifestVerifyResult{
+		Missing:  []string{"bin", "conf/log.yml"},
+		Extra:    []string{"extra"},
+		Modified: []string{"conf/app.yml"},
+	}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("VerifyChecksumManifest() = %+v, want %+v", result, want)
+	}
+}
@@ -0,0 +1,76 @@
+//go:build !linux
+// +build !linux
+
+package files
+
+import (
+	"archive/tar"
+	"errors"
+	"os"
+	"time"
+)
+
+var errMetaUnsupported = errors.New("当前操作系统不支持该操作")
+
+// 非Linux系统无法修改软链接本身的时间, 软链接直接跳过
+func setFileTimes(path string, atime, mtime time.Time) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return nil
+	}
+	if atime.IsZero() {
+		atime = mtime
+	}
+	return os.Chtimes(path, atime, mtime)
+}
+
+func fileAccessTime(info os.FileInfo) time.Time {
+	return time.Time{}
+}
+
+func makeSpecialFile(path string, hdr *tar.Header) error {
+	return errMetaUnsupported
+}
+
+func fileInode(info os.FileInfo) (dev, ino, nlink uint64, ok bool) {
+	return 0, 0, 0, false
+}
+
+func fileAllocatedSize(info os.FileInfo) int64 {
+	return info.Size()
+}
+
+func fileOwner(info os.FileInfo) (uid, gid int, ok bool) {
+	return 0, 0, false
+}
+
+func readXattrs(path string) (map[string][]byte, error) {
+	return nil, nil
+}
+
+func setXattr(path, name string, value []byte) error {
+	return errMetaUnsupported
+}
+
+func listXattrNames(path string) ([]string, error) {
+	return nil, errMetaUnsupported
+}
+
+func getXattr(path, name string) ([]byte, error) {
+	return nil, errMetaUnsupported
+}
+
+func removeXattr(path, name string) error {
+	return errMetaUnsupported
+}
+
+func isXattrNotFound(err error) bool {
+	return false
+}
+
+func isXattrUnsupported(err error) bool {
+	return errors.Is(err, errMetaUnsupported)
+}
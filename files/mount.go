@@ -0,0 +1,214 @@
+package files
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// 当前进程所在挂载命名空间的挂载表
+const mountInfoPath = "/proc/self/mountinfo"
+
+// 网络文件系统类型
+var networkFSTypes = map[string]bool{
+	"nfs": true, "nfs4": true, "cifs": true, "smb3": true, "smbfs": true, "ncpfs": true,
+	"afs": true, "9p": true, "ceph": true, "glusterfs": true, "lustre": true, "gfs2": true,
+	"ocfs2": true, "fuse.sshfs": true, "fuse.glusterfs": true, "fuse.cephfs": true, "davfs": true,
+}
+
+// mountinfo中的一条挂载记录
+type MountEntry struct {
+	ID           int      `json:"id"`
+	ParentID     int      `json:"parent_id"`
+	Major        int      `json:"major"`
+	Minor        int      `json:"minor"`
+	Root         string   `json:"root"`          // 挂载的源文件系统中的目录, bind挂载子目录时不为/
+	MountPoint   string   `json:"mount_point"`   // 挂载点
+	Options      []string `json:"options"`       // 挂载点选项, 如 rw、nosuid
+	Optional     []string `json:"optional"`      // 传播属性, 如 shared:1、master:2
+	FSType       string   `json:"fs_type"`       // 文件系统类型, 如 ext4、overlay、nfs4
+	Source       string   `json:"source"`        // 设备或来源, 如 /dev/sda1、server:/export
+	SuperOptions []string `json:"super_options"` // 文件系统超级块选项
+	// bind挂载: 挂载了文件系统的子目录, 或同一设备在之前已经挂载过
+	Bind bool `json:"bind"`
+}
+
+// 读取当前进程的挂载表, 按mountinfo中的顺序返回
+func ListMounts() ([]MountEntry, error) {
+	f, err := os.Open(mountInfoPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseMountInfo(f)
+}
+
+// 解析mountinfo格式的挂载表, 格式见 man 5 proc
+func ParseMountInfo(r io.Reader) ([]MountEntry, error) {
+	var mounts []MountEntry
+	seen := map[[2]int]bool{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		entry, err := parseMountInfoLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("解析挂载表第%d行失败: %v", lineNum, err)
+		}
+		dev := [2]int{entry.Major, entry.Minor}
+		entry.Bind = entry.Root != "/" || seen[dev]
+		seen[dev] = true
+		mounts = append(mounts, entry)
+	}
+	return mounts, scanner.Err()
+}
+
+// 36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+func parseMountInfoLine(line string) (MountEntry, error) {
+	var entry MountEntry
+	fields := strings.Fields(line)
+	sep := -1
+	for i := 6; i < len(fields); i++ {
+		if fields[i] == "-" {
+			sep = i
+			break
+		}
+	}
+	if sep < 0 || len(fields) < sep+3 {
+		return entry, fmt.Errorf("字段数量不足")
+	}
+	var err error
+	if entry.ID, err = strconv.Atoi(fields[0]); err != nil {
+		return entry, fmt.Errorf("无效的挂载ID: %s", fields[0])
+	}
+	if entry.ParentID, err = strconv.Atoi(fields[1]); err != nil {
+		return entry, fmt.Errorf("无效的父挂载ID: %s", fields[1])
+	}
+	major, minor, ok := strings.Cut(fields[2], ":")
+	if !ok {
+		return entry, fmt.Errorf("无效的设备号: %s", fields[2])
+	}
+	if entry.Major, err = strconv.Atoi(major); err != nil {
+		return entry, fmt.Errorf("无效的设备号: %s", fields[2])
+	}
+	if entry.Minor, err = strconv.Atoi(minor); err != nil {
+		return entry, fmt.Errorf("无效的设备号: %s", fields[2])
+	}
+	entry.Root = unescapeMountField(fields[3])
+	entry.MountPoint = unescapeMountField(fields[4])
+	entry.Options = strings.Split(fields[5], ",")
+	entry.Optional = fields[6:sep]
+	entry.FSType = fields[sep+1]
+	entry.Source = unescapeMountField(fields[sep+2])
+	if len(fields) > sep+3 {
+		entry.SuperOptions = strings.Split(fields[sep+3], ",")
+	}
+	return entry, nil
+}
+
+// 还原内核对空格、制表符、换行和反斜杠的八进制转义, 如 \040
+func unescapeMountField(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) && isOctal(s[i+1]) && isOctal(s[i+2]) && isOctal(s[i+3]) {
+			b.WriteByte((s[i+1]-'0')<<6 | (s[i+2]-'0')<<3 | (s[i+3] - '0'))
+			i += 3
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func isOctal(c byte) bool {
+	return c >= '0' && c <= '7'
+}
+
+/*
+查找路径所在的挂载记录
+1. 路径不存在时使用最近的已存在的上级目录, 软链接解析为实际路径
+2. 同一挂载点上叠加多次挂载时以最后一次为准
+*/
+func FindMount(path string) (*MountEntry, error) {
+	mounts, err := ListMounts()
+	if err != nil {
+		return nil, err
+	}
+	return findMountIn(mounts, path)
+}
+
+func findMountIn(mounts []MountEntry, path string) (*MountEntry, error) {
+	existing, err := nearestExistingPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if existing, err = filepath.EvalSymlinks(existing); err != nil {
+		return nil, err
+	}
+	var found *MountEntry
+	for i := range mounts {
+		mp := mounts[i].MountPoint
+		if !pathHasPrefix(existing, mp) {
+			continue
+		}
+		if found == nil || len(mp) >= len(found.MountPoint) {
+			found = &mounts[i]
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("未找到路径所在的挂载点: %s", path)
+	}
+	return found, nil
+}
+
+// 判断path是否位于dir之下(或就是dir)
+func pathHasPrefix(path, dir string) bool {
+	if dir == "/" || path == dir {
+		return true
+	}
+	return strings.HasPrefix(path, dir+"/")
+}
+
+// 是否包含指定的挂载选项, 同时检查挂载点选项和超级块选项
+func (m *MountEntry) HasOption(name string) bool {
+	for _, opts := range [][]string{m.Options, m.SuperOptions} {
+		for _, opt := range opts {
+			if opt == name || strings.HasPrefix(opt, name+"=") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 是否为只读挂载
+func (m *MountEntry) ReadOnly() bool {
+	return m.HasOption("ro")
+}
+
+// 是否为内存文件系统, 重启后内容丢失
+func (m *MountEntry) IsTmpfs() bool {
+	return m.FSType == "tmpfs" || m.FSType == "ramfs"
+}
+
+// 是否为overlay文件系统, 容器的根目录通常是overlay
+func (m *MountEntry) IsOverlay() bool {
+	return m.FSType == "overlay" || m.FSType == "fuse.fuse-overlayfs"
+}
+
+// 是否为网络文件系统
+func (m *MountEntry) IsNetwork() bool {
+	return networkFSTypes[m.FSType]
+}
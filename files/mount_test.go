@@ -0,0 +1,106 @@
+package files
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMountInfo(t *testing.T) {
+	input := strings.Join([]string{
+		"22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw,errors=remount-ro",
+		"",
+		"30 22 8:1 /data/app /srv/my\\040app ro,nosuid shared:1 master:2 - ext4 /dev/sda1 rw",
+		"31 22 0:45 / /var/lib/docker/overlay2/x/merged rw - overlay overlay rw,lowerdir=/l,upperdir=/u",
+		"32 22 0:50 / /mnt/nfs rw,noatime - nfs4 10.0.0.1:/export rw,vers=4.1",
+		"33 22 0:26 / /dev/shm rw - tmpfs tmpfs",
+		"34 22 0:51 / /home/u/.local/share/containers/storage/overlay/x/merged rw - fuse.fuse-overlayfs fuse-overlayfs rw,user_id=0",
+	}, "\n")
+	mounts, err := ParseMountInfo(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseMountInfo() error = %v", err)
+	}
+	if len(mounts) != 6 {
+		t.Fatalf("ParseMountInfo() 返回%d条, want 6", len(mounts))
+	}
+	want := MountEntry{
+		ID: 30, ParentID: 22, Major: 8, Minor: 1,
+		Root: "/data/app", MountPoint: "/srv/my app",
+		Options: []string{"ro", "nosuid"}, Optional: []string{"shared:1", "master:2"},
+		FSType: "ext4", Source: "/dev/sda1", SuperOptions: []string{"rw"}, Bind: true,
+	}
+	if !reflect.DeepEqual(mounts[1], want) {
+		t.Errorf("ParseMountInfo()[1] = %+v, want %+v", mounts[1], want)
+	}
+
+	tests := []struct {
+		name                                   string
+		entry                                  MountEntry
+		bind, readOnly, tmpfs, overlay, remote bool
+	}{
+		{"根目录", mounts[0], false, false, false, false, false},
+		{"bind挂载", mounts[1], true, true, false, false, false},
+		{"overlay", mounts[2], false, false, false, true, false},
+		{"nfs", mounts[3], false, false, false, false, true},
+		{"tmpfs", mounts[4], false, false, true, false, false},
+		{"fuse-overlayfs", mounts[5], false, false, false, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []bool{tt.entry.Bind, tt.entry.ReadOnly(), tt.entry.IsTmpfs(), tt.entry.IsOverlay(), tt.entry.IsNetwork()}
+			want := []bool{tt.bind, tt.readOnly, tt.tmpfs, tt.overlay, tt.remote}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Bind/ReadOnly/IsTmpfs/IsOverlay/IsNetwork = %v, want %v", got, want)
+			}
+		})
+	}
+	if !mounts[2].HasOption("lowerdir") || mounts[2].HasOption("lower") {
+		t.Errorf("HasOption() 应该按选项名完整匹配: %v", mounts[2].SuperOptions)
+	}
+
+	for _, line := range []string{"22 1 8:1 / / rw", "x 1 8:1 / / rw - ext4 /dev/sda1 rw", "22 1 8 / / rw - ext4 /dev/sda1 rw"} {
+		if _, err := ParseMountInfo(strings.NewReader(line)); err == nil {
+			t.Errorf("ParseMountInfo(%q) error = nil, want error", line)
+		}
+	}
+}
+
+func TestFindMountIn(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	mustDo(t, err)
+	mustDo(t, os.MkdirAll(filepath.Join(dir, "a"), 0755))
+	mustDo(t, os.MkdirAll(filepath.Join(dir, "ab"), 0755))
+	mounts := []MountEntry{
+		{ID: 1, MountPoint: "/"},
+		{ID: 2, MountPoint: dir},
+		{ID: 3, MountPoint: filepath.Join(dir, "a")},
+		{ID: 4, MountPoint: filepath.Join(dir, "a")}, // 叠加在同一挂载点上
+	}
+	tests := []struct {
+		name string
+		path string
+		want int
+	}{
+		{"挂载点本身", dir, 2},
+		{"叠加的挂载点", filepath.Join(dir, "a"), 4},
+		{"挂载点下不存在的路径", filepath.Join(dir, "a", "b", "c"), 4},
+		{"前缀相同的其他目录", filepath.Join(dir, "ab"), 2},
+		{"根目录", "/", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findMountIn(mounts, tt.path)
+			if err != nil {
+				t.Fatalf("findMountIn() error = %v", err)
+			}
+			if got.ID != tt.want {
+				t.Errorf("findMountIn(%s) = %d, want %d", tt.path, got.ID, tt.want)
+			}
+		})
+	}
+	if _, err := findMountIn(mounts[1:2], "/"); err == nil {
+		t.Errorf("findMountIn() error = nil, want error")
+	}
+}
@@ -0,0 +1,152 @@
+package files
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// gitignore风格的路径匹配器
+//  1. 不含/的规则匹配任意层级的文件名, 如 *.log
+//  2. 以/开头或中间含有/的规则相对于根目录匹配, 如 /build、conf/*.yml
+//  3. 以/结尾的规则只匹配目录, 如 tmp/
+//  4. ** 匹配任意层级的目录, 如 **/cache、logs/**
+//  5. 以!开头的规则表示取反, 后面的规则优先级更高
+//  6. 目录被匹配时, 其下所有文件视为同样被匹配
+type PathMatcher struct {
+	patterns []pathPattern
+}
+
+type pathPattern struct {
+	negate   bool
+	dirOnly  bool
+	segments []string
+}
+
+// 编译gitignore风格的规则, 空行和#开头的行会被忽略
+func NewPathMatcher(patterns []string) (*PathMatcher, error) {
+	m := &PathMatcher{}
+	for _, raw := range patterns {
+		p := pathPattern{}
+		rule := strings.TrimSpace(raw)
+		if rule == "" || strings.HasPrefix(rule, "#") {
+			continue
+		}
+		if strings.HasPrefix(rule, "!") {
+			p.negate = true
+			rule = rule[1:]
+		}
+		if strings.HasSuffix(rule, "/") {
+			p.dirOnly = true
+			rule = strings.TrimRight(rule, "/")
+		}
+		if strings.Trim(rule, "/") == "" {
+			return nil, fmt.Errorf("无效的匹配规则: %q", raw)
+		}
+		// 不含/的规则可以匹配任意层级
+		if !strings.Contains(rule, "/") {
+			rule = "**/" + rule
+		}
+		rule = strings.TrimPrefix(rule, "/")
+		p.segments = strings.Split(rule, "/")
+		for _, seg := range p.segments {
+			if _, err := path.Match(seg, ""); err != nil {
+				return nil, fmt.Errorf("无效的匹配规则: %q %v", raw, err)
+			}
+		}
+		m.patterns = append(m.patterns, p)
+	}
+	return m, nil
+}
+
+// 没有任何有效规则
+func (m *PathMatcher) Empty() bool {
+	return m == nil || len(m.patterns) == 0
+}
+
+// 判断以/分隔的相对路径是否匹配, isDir表示该路径是否为目录; 路径本身或任意上级目录被匹配都视为匹配
+func (m *PathMatcher) Match(relPath string, isDir bool) bool {
+	if m.Empty() {
+		return false
+	}
+	relPath = strings.Trim(path.Clean("/"+relPath), "/")
+	if relPath == "" {
+		return false
+	}
+	segments := strings.Split(relPath, "/")
+	matched := false
+	for _, p := range m.patterns {
+		if p.matchWithParents(segments, isDir) {
+			matched = !p.negate
+		}
+	}
+	return matched
+}
+
+func (p pathPattern) matchWithParents(segments []string, isDir bool) bool {
+	for i := len(segments); i > 0; i-- {
+		// 上级路径一定是目录
+		dir := i < len(segments) || isDir
+		if p.dirOnly && !dir {
+			continue
+		}
+		if matchSegments(p.segments, segments[:i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// 逐段匹配, ** 可以匹配零个或多个路径段
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(rest, segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// 包含和排除规则的组合, 排除规则优先
+type pathFilter struct {
+	include *PathMatcher
+	exclude *PathMatcher
+}
+
+func newPathFilter(include, exclude []string) (*pathFilter, error) {
+	includeMatcher, err := NewPathMatcher(include)
+	if err != nil {
+		return nil, err
+	}
+	excludeMatcher, err := NewPathMatcher(exclude)
+	if err != nil {
+		return nil, err
+	}
+	return &pathFilter{include: includeMatcher, exclude: excludeMatcher}, nil
+}
+
+// 目录被排除时整个子树都跳过
+func (f *pathFilter) skipDir(relPath string) bool {
+	return f.exclude.Match(relPath, true)
+}
+
+// 文件未被排除, 并且在没有包含规则或匹配包含规则时保留
+func (f *pathFilter) keepFile(relPath string) bool {
+	if f.exclude.Match(relPath, false) {
+		return false
+	}
+	return f.include.Empty() || f.include.Match(relPath, false)
+}
@@ -0,0 +1,44 @@
+package files
+
+import "testing"
+
+func TestPathMatcher(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		path     string
+		isDir    bool
+		want     bool
+	}{
+		{"任意层级的文件名", []string{"*.log"}, "a/b/app.log", false, true},
+		{"相对根目录", []string{"/build"}, "src/build", true, false},
+		{"相对根目录匹配", []string{"/build"}, "build/out.bin", false, true},
+		{"中间含有/", []string{"conf/*.yml"}, "conf/app.yml", false, true},
+		{"中间含有/不匹配子目录", []string{"conf/*.yml"}, "x/conf/app.yml", false, false},
+		{"只匹配目录", []string{"tmp/"}, "tmp", false, false},
+		{"目录下的文件", []string{"tmp/"}, "a/tmp/x.txt", false, true},
+		{"双星号前缀", []string{"**/cache"}, "a/b/cache/x", false, true},
+		{"双星号后缀", []string{"logs/**"}, "logs/a/b.log", false, true},
+		{"取反", []string{"*.log", "!keep.log"}, "keep.log", false, false},
+		{"后面的规则优先", []string{"!keep.log", "*.log"}, "keep.log", false, true},
+		{"忽略空行和注释", []string{"", "# *.log"}, "app.log", false, false},
+		{"规范化路径", []string{"/a/b"}, "./a//b/", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := NewPathMatcher(tt.patterns)
+			if err != nil {
+				t.Fatalf("NewPathMatcher() error = %v", err)
+			}
+			if got := m.Match(tt.path, tt.isDir); got != tt.want {
+				t.Errorf("Match(%s, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+			}
+		})
+	}
+
+	for _, pattern := range []string{"/", "[a"} {
+		if _, err := NewPathMatcher([]string{pattern}); err == nil {
+			t.Errorf("NewPathMatcher(%q) error = nil, want error", pattern)
+		}
+	}
+}
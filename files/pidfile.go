@@ -0,0 +1,103 @@
+package files
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/toddlerya/glue/ps"
+)
+
+// PID文件, 同一时间只允许一个进程持有
+type PIDFile struct {
+	lock *FileLock
+}
+
+/*
+创建PID文件并写入当前进程号, 文件在持有期间保持加锁
+1. 文件已被其他进程锁定时返回ErrLocked
+2. 文件中记录的进程仍在运行时同样返回ErrLocked, 兼容不加锁的旧版本程序
+3. 记录的进程已经退出, 或进程号已被其他程序复用(进程名与当前进程不同)时视为残留文件, 直接覆盖
+*/
+func CreatePIDFile(path string) (*PIDFile, error) {
+	lock, err := TryLockFile(path, LockOptions{})
+	if err != nil {
+		if err == ErrLocked {
+			if pid, readErr := ReadPIDFile(path); readErr == nil {
+				return nil, fmt.Errorf("%w: 进程%d正在运行", ErrLocked, pid)
+			}
+		}
+		return nil, err
+	}
+	if pid, err := ReadPIDFile(path); err == nil && pid != os.Getpid() && isPIDFileOwnerAlive(pid) {
+		lock.Unlock()
+		return nil, fmt.Errorf("%w: 进程%d正在运行", ErrLocked, pid)
+	}
+
+	f := lock.file
+	if err = f.Truncate(0); err == nil {
+		if _, err = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err == nil {
+			err = f.Sync()
+		}
+	}
+	if err != nil {
+		lock.Unlock()
+		return nil, err
+	}
+	return &PIDFile{lock: lock}, nil
+}
+
+// 判断PID文件中记录的进程是否仍在运行
+func isPIDFileOwnerAlive(pid int) bool {
+	alive, err := ps.IsProcessAlive(int32(pid))
+	if err != nil {
+		// 无法判断时按仍在运行处理, 避免两个进程同时运行
+		return true
+	}
+	if !alive {
+		return false
+	}
+	owner, err := ps.GetProcessInfoByPid(int32(pid))
+	if err != nil {
+		return true
+	}
+	self, err := ps.GetProcessInfoByPid(int32(os.Getpid()))
+	if err != nil {
+		return true
+	}
+	return owner.Name == self.Name
+}
+
+// 读取PID文件中记录的进程号
+func ReadPIDFile(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	content, err := io.ReadAll(io.LimitReader(f, 64))
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("PID文件内容无效: %s", path)
+	}
+	return pid, nil
+}
+
+// PID文件的路径
+func (p *PIDFile) Path() string {
+	return p.lock.Path()
+}
+
+// 删除PID文件并释放锁, 先删除再解锁, 等待中的进程加锁后发现文件已被删除会重新创建
+func (p *PIDFile) Remove() error {
+	err := os.Remove(p.lock.Path())
+	if unlockErr := p.lock.Unlock(); err == nil {
+		err = unlockErr
+	}
+	return err
+}
@@ -0,0 +1,51 @@
+package files
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestCreatePIDFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.pid")
+	pidFile, err := CreatePIDFile(path)
+	if err != nil {
+		t.Fatalf("CreatePIDFile() error = %v", err)
+	}
+	if pid, err := ReadPIDFile(path); err != nil || pid != os.Getpid() {
+		t.Errorf("ReadPIDFile() = %d, %v, want %d", pid, err, os.Getpid())
+	}
+	if _, err = CreatePIDFile(path); !errors.Is(err, ErrLocked) {
+		t.Errorf("重复创建 error = %v, want ErrLocked", err)
+	}
+	mustDo(t, pidFile.Remove())
+	if _, err = os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Remove之后PID文件仍然存在: %v", err)
+	}
+
+	// 记录的进程已经退出, 视为残留文件
+	cmd := exec.Command("true")
+	if err = cmd.Run(); err != nil {
+		t.Skip("无法运行true命令:", err)
+	}
+	mustDo(t, os.WriteFile(path, []byte(strconv.Itoa(cmd.Process.Pid)+"\n"), 0644))
+	pidFile, err = CreatePIDFile(path)
+	if err != nil {
+		t.Fatalf("残留的PID文件应被覆盖: %v", err)
+	}
+	defer pidFile.Remove()
+	if pid, _ := ReadPIDFile(path); pid != os.Getpid() {
+		t.Errorf("ReadPIDFile() = %d, want %d", pid, os.Getpid())
+	}
+}
+
+func TestReadPIDFileInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.pid")
+	mustDo(t, os.WriteFile(path, []byte("not-a-pid"), 0644))
+	if _, err := ReadPIDFile(path); err == nil {
+		t.Error("ReadPIDFile() 内容无效时应返回错误")
+	}
+}
@@ -0,0 +1,124 @@
+package files
+
+import (
+	"context"
+	"io"
+	"os"
+)
+
+// 进度信息
+type Progress struct {
+	Done  int64  `json:"done"`  // 已处理的字节数
+	Total int64  `json:"total"` // 总字节数, 无法预知时为-1
+	Path  string `json:"path"`  // 当前正在处理的文件
+}
+
+// 进度回调, 每处理一块数据调用一次, 在执行操作的goroutine中同步调用
+type ProgressFunc func(p Progress)
+
+// 累计进度并调用回调, 为nil时不做任何事
+type progressTracker struct {
+	fn    ProgressFunc
+	done  int64
+	total int64
+}
+
+func newProgressTracker(fn ProgressFunc, total int64) *progressTracker {
+	if fn == nil {
+		return nil
+	}
+	return &progressTracker{fn: fn, total: total}
+}
+
+func (t *progressTracker) add(n int64, path string) {
+	if t == nil {
+		return
+	}
+	t.done += n
+	t.fn(Progress{Done: t.done, Total: t.total, Path: path})
+}
+
+// 复制时每块数据的大小
+const copyBufferSize = 256 * 1024
+
+// 支持取消的复制, 每复制一块数据检查一次ctx并回调onWrite
+func copyContext(ctx context.Context, dst io.Writer, src io.Reader, onWrite func(n int64)) (int64, error) {
+	buf := make([]byte, copyBufferSize)
+	var written int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return written, err
+		}
+		nr, readErr := src.Read(buf)
+		if nr > 0 {
+			nw, writeErr := dst.Write(buf[:nr])
+			written += int64(nw)
+			if onWrite != nil && nw > 0 {
+				onWrite(int64(nw))
+			}
+			if writeErr != nil {
+				return written, writeErr
+			}
+			if nw != nr {
+				return written, io.ErrShortWrite
+			}
+		}
+		if readErr == io.EOF {
+			return written, nil
+		} else if readErr != nil {
+			return written, readErr
+		}
+	}
+}
+
+// 支持取消和进度回调的数据复制, total为总字节数(未知时传-1), path用于在进度回调中标识当前处理的对象
+func CopyReaderWithContext(ctx context.Context, dst io.Writer, src io.Reader, total int64, path string, progress ProgressFunc) (int64, error) {
+	tracker := newProgressTracker(progress, total)
+	return copyContext(ctx, dst, src, func(n int64) {
+		tracker.add(n, path)
+	})
+}
+
+// 统计读取字节数的reader
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// 支持取消和进度回调的文件拷贝, 先写入临时文件再重命名, 新文件的权限与源文件一致
+func CopyWithContext(ctx context.Context, srcFile, dstFile string, progress ProgressFunc) error {
+	in, err := os.Open(srcFile)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	tracker := newProgressTracker(progress, info.Size())
+	return copyFileContext(ctx, in, info, dstFile, tracker)
+}
+
+// 将已打开的源文件拷贝到dstFile
+func copyFileContext(ctx context.Context, in *os.File, info os.FileInfo, dstFile string, tracker *progressTracker) error {
+	perm := info.Mode().Perm()
+	if dstInfo, err := os.Stat(dstFile); err == nil {
+		// 覆盖已存在的文件时保持其原有权限, 与os.Create的行为一致
+		perm = dstInfo.Mode().Perm()
+	}
+	out, err := createTempSibling(dstFile, perm)
+	if err != nil {
+		return err
+	}
+	_, err = copyFileData(ctx, out, in, func(n int64) {
+		tracker.add(n, in.Name())
+	})
+	return finishTempSibling(out, dstFile, err)
+}
@@ -0,0 +1,69 @@
+package files
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCopyReaderWithContext(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), copyBufferSize*2+10)
+	var last Progress
+	calls := 0
+	var buf bytes.Buffer
+	n, err := CopyReaderWithContext(context.Background(), &buf, bytes.NewReader(data), int64(len(data)), "mem", func(p Progress) {
+		calls++
+		last = p
+	})
+	if err != nil || n != int64(len(data)) || !bytes.Equal(buf.Bytes(), data) {
+		t.Fatalf("CopyReaderWithContext() = %d, %v", n, err)
+	}
+	if want := (Progress{Done: int64(len(data)), Total: int64(len(data)), Path: "mem"}); last != want || calls < 2 {
+		t.Errorf("最后一次进度 = %+v, 回调%d次, want %+v", last, calls, want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = CopyReaderWithContext(ctx, &buf, bytes.NewReader(data), -1, "", nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("CopyReaderWithContext() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestCopyWithContext(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.bin")
+	dst := filepath.Join(dir, "dst.bin")
+	data := bytes.Repeat([]byte("0123456789"), 100000)
+	mustDo(t, os.WriteFile(src, data, 0640))
+
+	var last Progress
+	err := CopyWithContext(context.Background(), src, dst, func(p Progress) { last = p })
+	if err != nil {
+		t.Fatalf("CopyWithContext() error = %v", err)
+	}
+	if content, _ := os.ReadFile(dst); !bytes.Equal(content, data) {
+		t.Errorf("拷贝后的内容不一致")
+	}
+	if info, err := os.Stat(dst); err != nil || info.Mode().Perm() != 0640 {
+		t.Errorf("拷贝后的权限 = %v, %v, want %v", info.Mode().Perm(), err, os.FileMode(0640))
+	}
+	if last.Done != int64(len(data)) || last.Total != int64(len(data)) {
+		t.Errorf("最后一次进度 = %+v, want Done = Total = %d", last, len(data))
+	}
+
+	// 取消时不会留下不完整的文件
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	canceled := filepath.Join(dir, "canceled.bin")
+	if err = CopyWithContext(ctx, src, canceled, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("CopyWithContext() error = %v, want %v", err, context.Canceled)
+	}
+	entries, err := os.ReadDir(dir)
+	mustDo(t, err)
+	if len(entries) != 2 {
+		t.Errorf("取消后目录中有%d个文件, want 2", len(entries))
+	}
+}
@@ -0,0 +1,179 @@
+package files
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// 判断新旧的排序方式
+type PruneSortBy int
+
+const (
+	PruneByModTime PruneSortBy = iota // 按修改时间, 越晚越新
+	PruneByName                       // 按文件名, 字典序越大越新, 适用于文件名中带有时间戳的文件
+)
+
+// 清理的原因
+const (
+	PruneReasonCount = "count" // 超过保留数量
+	PruneReasonAge   = "age"   // 超过保留时间
+	PruneReasonSize  = "size"  // 超过保留的总大小
+)
+
+/*
+按保留策略清理文件的选项, 设置的多个限制中任意一个被超出时删除
+例如 KeepLast: 5, MaxAge: 30天 表示只保留最新的5个, 并且删除其中超过30天的
+*/
+type PruneOptions struct {
+	// 文件名的glob规则, 如 app-*.tar.gz, 不能为空; 非递归时匹配的目录也会作为候选
+	Pattern string
+	// 递归查找子目录中的文件, 此时只有文件作为候选
+	Recursive bool
+	// 判断新旧的方式, 默认按修改时间
+	SortBy PruneSortBy
+	// 只保留最新的N个, 0表示不限制
+	KeepLast int
+	// 删除修改时间早于该时长之前的, 0表示不限制
+	MaxAge time.Duration
+	// 从最新的开始累加大小, 超出该值的删除, 0表示不限制
+	MaxTotalSize int64
+	// 无论其他限制如何, 最新的N个总是保留
+	MinKeep int
+	// 只计算需要删除的文件, 不实际删除
+	DryRun bool
+	// 删除每个文件之前调用, 返回false时保留该文件
+	BeforeDelete func(file PruneFile) bool
+}
+
+// 候选文件
+type PruneFile struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	IsDir   bool      `json:"is_dir"`
+	Reason  string    `json:"reason,omitempty"` // 删除的原因, 保留的文件为空
+}
+
+// 清理结果, 按从新到旧排列
+type PruneReport struct {
+	Kept    []PruneFile `json:"kept"`
+	Deleted []PruneFile `json:"deleted"` // DryRun时为计划删除的文件
+	DryRun  bool        `json:"dry_run"`
+}
+
+// 带路径的文件信息, 用于复用SortFileName系列函数
+type pruneFileInfo struct {
+	os.FileInfo
+	path string
+	size int64
+}
+
+// 按保留策略清理目录下匹配的文件, 删除失败时继续处理其余文件, 返回结果和第一个错误
+func Prune(dirPath string, opts PruneOptions) (*PruneReport, error) {
+	if opts.Pattern == "" {
+		return nil, fmt.Errorf("文件名规则不能为空")
+	}
+	if _, err := filepath.Match(opts.Pattern, ""); err != nil {
+		return nil, fmt.Errorf("无效的文件名规则: %q %v", opts.Pattern, err)
+	}
+	candidates, err := pruneCandidates(dirPath, opts)
+	if err != nil {
+		return nil, err
+	}
+	switch opts.SortBy {
+	case PruneByName:
+		SortFileNameDescend(candidates)
+	default:
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].ModTime().After(candidates[j].ModTime())
+		})
+	}
+
+	report := &PruneReport{DryRun: opts.DryRun}
+	var firstErr error
+	var totalSize int64
+	now := time.Now()
+	for i, candidate := range candidates {
+		info := candidate.(*pruneFileInfo)
+		file := PruneFile{Path: info.path, Size: info.size, ModTime: info.ModTime(), IsDir: info.IsDir()}
+		totalSize += info.size
+		if i >= opts.MinKeep {
+			switch {
+			case opts.KeepLast > 0 && i >= opts.KeepLast:
+				file.Reason = PruneReasonCount
+			case opts.MaxAge > 0 && now.Sub(info.ModTime()) > opts.MaxAge:
+				file.Reason = PruneReasonAge
+			case opts.MaxTotalSize > 0 && totalSize > opts.MaxTotalSize:
+				file.Reason = PruneReasonSize
+			}
+		}
+		if file.Reason == "" || opts.BeforeDelete != nil && !opts.BeforeDelete(file) {
+			file.Reason = ""
+			report.Kept = append(report.Kept, file)
+			continue
+		}
+		if !opts.DryRun {
+			if err := Delete(info.path); err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				file.Reason = ""
+				report.Kept = append(report.Kept, file)
+				continue
+			}
+		}
+		report.Deleted = append(report.Deleted, file)
+	}
+	return report, firstErr
+}
+
+func pruneCandidates(dirPath string, opts PruneOptions) ([]os.FileInfo, error) {
+	var candidates []os.FileInfo
+	if opts.Recursive {
+		paths, err := SearchFileInPath(dirPath, opts.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			info, err := os.Lstat(path)
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			candidates = append(candidates, &pruneFileInfo{FileInfo: info, path: path, size: info.Size()})
+		}
+		return candidates, nil
+	}
+
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if ok, _ := filepath.Match(opts.Pattern, entry.Name()); !ok {
+			continue
+		}
+		info, err := entry.Info()
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		path := filepath.Join(dirPath, entry.Name())
+		size := info.Size()
+		if info.IsDir() {
+			usage, err := CalcDiskUsage(context.Background(), path, DiskUsageOptions{})
+			if err != nil {
+				return nil, err
+			}
+			size = usage.ApparentSize
+		}
+		candidates = append(candidates, &pruneFileInfo{FileInfo: info, path: path, size: size})
+	}
+	return candidates, nil
+}
@@ -0,0 +1,120 @@
+package files
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// 创建 app-1.tar.gz ~ app-5.tar.gz, 每个10字节, app-N的修改时间为(5-N)天之前
+func writePruneFiles(t *testing.T, dir string, reverse bool) {
+	now := time.Now()
+	for i := 1; i <= 5; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("app-%d.tar.gz", i))
+		mustDo(t, os.WriteFile(path, []byte("0123456789"), 0644))
+		age := 5 - i
+		if reverse {
+			age = i
+		}
+		modTime := now.Add(-time.Duration(age) * 24 * time.Hour)
+		mustDo(t, os.Chtimes(path, modTime, modTime))
+	}
+	mustDo(t, os.WriteFile(filepath.Join(dir, "other.txt"), nil, 0644))
+}
+
+func pruneNames(files []PruneFile) []string {
+	var names []string
+	for _, file := range files {
+		names = append(names, filepath.Base(file.Path))
+	}
+	return names
+}
+
+func TestPrune(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        PruneOptions
+		reverse     bool
+		wantDeleted []string
+		wantReason  string
+	}{
+		{"保留最新的2个", PruneOptions{KeepLast: 2}, false, []string{"app-3.tar.gz", "app-2.tar.gz", "app-1.tar.gz"}, PruneReasonCount},
+		{"超过保留时间", PruneOptions{MaxAge: 60 * time.Hour}, false, []string{"app-2.tar.gz", "app-1.tar.gz"}, PruneReasonAge},
+		{"超过总大小", PruneOptions{MaxTotalSize: 25}, false, []string{"app-3.tar.gz", "app-2.tar.gz", "app-1.tar.gz"}, PruneReasonSize},
+		{"最少保留3个", PruneOptions{KeepLast: 1, MinKeep: 3}, false, []string{"app-2.tar.gz", "app-1.tar.gz"}, PruneReasonCount},
+		{"删除前回调", PruneOptions{KeepLast: 2, BeforeDelete: func(file PruneFile) bool {
+			return filepath.Base(file.Path) != "app-1.tar.gz"
+		}}, false, []string{"app-3.tar.gz", "app-2.tar.gz"}, PruneReasonCount},
+		{"按文件名排序", PruneOptions{KeepLast: 2, SortBy: PruneByName}, true, []string{"app-3.tar.gz", "app-2.tar.gz", "app-1.tar.gz"}, PruneReasonCount},
+		{"按修改时间排序", PruneOptions{KeepLast: 2}, true, []string{"app-3.tar.gz", "app-4.tar.gz", "app-5.tar.gz"}, PruneReasonCount},
+		{"未超出限制", PruneOptions{KeepLast: 5}, false, nil, ""},
+	}
+	for _, tt := range tests {
+		for _, dryRun := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/DryRun=%v", tt.name, dryRun), func(t *testing.T) {
+				dir := t.TempDir()
+				writePruneFiles(t, dir, tt.reverse)
+				opts := tt.opts
+				opts.Pattern = "app-*.tar.gz"
+				opts.DryRun = dryRun
+				report, err := Prune(dir, opts)
+				if err != nil {
+					t.Fatalf("Prune() error = %v", err)
+				}
+				if got := pruneNames(report.Deleted); !reflect.DeepEqual(got, tt.wantDeleted) {
+					t.Errorf("Prune().Deleted = %v, want %v", got, tt.wantDeleted)
+				}
+				if len(report.Kept)+len(report.Deleted) != 5 {
+					t.Errorf("Prune() 保留%d个, 删除%d个, want 共5个", len(report.Kept), len(report.Deleted))
+				}
+				for _, file := range report.Deleted {
+					if file.Reason != tt.wantReason {
+						t.Errorf("%s 删除原因 = %s, want %s", file.Path, file.Reason, tt.wantReason)
+					}
+					if _, err := os.Stat(file.Path); os.IsNotExist(err) == dryRun {
+						t.Errorf("DryRun=%v 时 %s 存在状态错误: %v", dryRun, file.Path, err)
+					}
+				}
+				for _, file := range report.Kept {
+					if file.Reason != "" {
+						t.Errorf("%s 保留原因 = %s, want 空", file.Path, file.Reason)
+					}
+					if _, err := os.Stat(file.Path); err != nil {
+						t.Errorf("保留的文件 %s 不存在: %v", file.Path, err)
+					}
+				}
+				if _, err := os.Stat(filepath.Join(dir, "other.txt")); err != nil {
+					t.Errorf("不匹配的文件被删除: %v", err)
+				}
+			})
+		}
+	}
+}
+
+func TestPruneRecursive(t *testing.T) {
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "sub")
+	writePruneFiles(t, dir, false)
+	mustDo(t, os.MkdirAll(sub, 0755))
+	mustDo(t, os.WriteFile(filepath.Join(sub, "app-0.tar.gz"), nil, 0644))
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	mustDo(t, os.Chtimes(filepath.Join(sub, "app-0.tar.gz"), old, old))
+
+	report, err := Prune(dir, PruneOptions{Pattern: "app-*.tar.gz", Recursive: true, KeepLast: 5})
+	if err != nil {
+		t.Fatalf("Prune() error = %v", err)
+	}
+	if got, want := pruneNames(report.Deleted), []string{"app-0.tar.gz"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Prune().Deleted = %v, want %v", got, want)
+	}
+
+	if _, err := Prune(dir, PruneOptions{Pattern: "[app"}); err == nil {
+		t.Errorf("Prune(无效的规则) error = nil, want error")
+	}
+	if _, err := Prune(dir, PruneOptions{KeepLast: 1}); err == nil {
+		t.Errorf("Prune(空规则) error = nil, want error")
+	}
+}
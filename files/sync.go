@@ -0,0 +1,369 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"syscall"
+)
+
+// 同步时判断文件是否发生变化的方式
+type SyncCompare int
+
+const (
+	SyncCompareSizeAndTime SyncCompare = iota // 比较大小和修改时间
+	SyncCompareChecksum                       // 大小相同时比较校验和
+)
+
+// 同步动作的类型
+type SyncActionType string
+
+const (
+	SyncActionCreate SyncActionType = "create" // 目标不存在, 新建
+	SyncActionUpdate SyncActionType = "update" // 目标已存在但内容或类型不同, 覆盖
+	SyncActionDelete SyncActionType = "delete" // 源目录中已不存在, 从目标删除
+)
+
+// 同步动作
+type SyncAction struct {
+	Type  SyncActionType `json:"type"`
+	Path  string         `json:"path"` // 相对于同步根目录, 以/分隔
+	IsDir bool           `json:"is_dir"`
+}
+
+// 目录同步的选项
+type SyncOptions struct {
+	// 拷贝选项, 其中Overwrite不生效; 修改时间总是会保留, 否则下次同步无法按时间比较
+	CopyOptions
+	// 比较方式, 默认比较大小和修改时间
+	Compare SyncCompare
+	// 按校验和比较时使用的算法, 默认sha256
+	Algorithm HashAlgorithm
+	// 删除源目录中已不存在的文件, 被Exclude规则匹配的文件不会被删除
+	Delete bool
+	// 只计算需要执行的动作, 不修改目标目录
+	DryRun bool
+}
+
+/*
+将源目录同步到目标目录, 只拷贝发生变化的文件, 返回计划执行(DryRun)或已经执行的动作列表
+1. 删除动作排在最前, 其余动作按目录遍历顺序排列, 上级目录总是在其内容之前
+2. 删除目录时只记录目录本身, 不再列出其下的文件
+*/
+func SyncDirectory(ctx context.Context, srcDir, dstDir string, opts SyncOptions) ([]SyncAction, error) {
+	info, err := os.Stat(srcDir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &CopyError{Path: srcDir, Err: fmt.Errorf("不是目录")}
+	}
+	if opts.Algorithm == "" {
+		opts.Algorithm = HashSHA256
+	}
+	opts.PreserveTimes = true
+	filter, err := newPathFilter(opts.Include, opts.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	s := &syncer{
+		treeCopier: &treeCopier{ctx: ctx, opts: opts.CopyOptions, filter: filter, visited: map[[2]uint64]bool{}},
+		syncOpts:   opts,
+		srcRoot:    srcDir,
+		dstRoot:    dstDir,
+	}
+	if err = s.planDir(srcDir, dstDir, ".", info, nil); err != nil {
+		return nil, err
+	}
+	actions := append(s.deletes, s.copies...)
+	if opts.DryRun {
+		return actions, nil
+	}
+	if opts.Progress != nil {
+		s.tracker = newProgressTracker(opts.Progress, s.copySize)
+	}
+	if err = s.execute(); err != nil {
+		return actions, err
+	}
+	if len(s.errs) > 0 {
+		return actions, s.errs
+	}
+	return actions, nil
+}
+
+type syncer struct {
+	*treeCopier
+	syncOpts SyncOptions
+	srcRoot  string
+	dstRoot  string
+	deletes  []SyncAction
+	copies   []SyncAction
+	infos    map[string]os.FileInfo // 需要拷贝的条目以及全部同步目录的源信息
+	dirs     []string               // 同步的目录, 执行完成后倒序设置元数据
+	copySize int64
+}
+
+func (s *syncer) addCopy(actionType SyncActionType, rel string, info os.FileInfo) {
+	if s.infos == nil {
+		s.infos = map[string]os.FileInfo{}
+	}
+	s.infos[rel] = info
+	if !info.IsDir() {
+		s.copies = append(s.copies, SyncAction{Type: actionType, Path: rel})
+		if info.Mode().IsRegular() {
+			s.copySize += info.Size()
+		}
+		return
+	}
+	if actionType != "" {
+		s.copies = append(s.copies, SyncAction{Type: actionType, Path: rel, IsDir: true})
+	}
+	s.dirs = append(s.dirs, rel)
+}
+
+// 遍历源目录计划同步动作, 目录的创建与CopyDirectory一样延迟到第一次需要时
+func (s *syncer) planDir(src, dst, rel string, info os.FileInfo, ensureParent func() error) error {
+	if dev, ino, _, ok := fileInode(info); ok {
+		key := [2]uint64{dev, ino}
+		if s.visited[key] {
+			return s.fail(src, fmt.Errorf("跟随软链接时发现循环"))
+		}
+		s.visited[key] = true
+		defer delete(s.visited, key)
+	}
+
+	planned := false
+	ensure := func() error {
+		if planned {
+			return nil
+		}
+		if ensureParent != nil {
+			if err := ensureParent(); err != nil {
+				return err
+			}
+		}
+		planned = true
+		dstInfo, err := os.Lstat(dst)
+		switch {
+		case isNotExistOrNotDir(err):
+			s.addCopy(SyncActionCreate, rel, info)
+		case err != nil:
+			return err
+		case !dstInfo.IsDir():
+			s.addCopy(SyncActionUpdate, rel, info)
+		default:
+			s.addCopy("", rel, info)
+		}
+		return nil
+	}
+	if rel == "." || s.filter.include.Empty() || s.filter.include.Match(rel, true) {
+		if err := ensure(); err != nil {
+			return s.fail(dst, err)
+		}
+	}
+
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return s.fail(src, err)
+	}
+	names := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		if err := s.ctx.Err(); err != nil {
+			return err
+		}
+		names[entry.Name()] = true
+		if err := s.planEntry(filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name()), path.Join(rel, entry.Name()), ensure); err != nil {
+			return err
+		}
+	}
+	if s.syncOpts.Delete && planned {
+		return s.planDeletes(dst, rel, names)
+	}
+	return nil
+}
+
+func (s *syncer) planEntry(src, dst, rel string, ensureParent func() error) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return s.fail(src, err)
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		switch s.opts.Symlinks {
+		case SymlinkSkip:
+			return nil
+		case SymlinkFollow:
+			if info, err = os.Stat(src); err != nil {
+				return s.fail(src, err)
+			}
+		}
+	}
+
+	if info.IsDir() {
+		if s.filter.skipDir(rel) {
+			return nil
+		}
+		return s.planDir(src, dst, rel, info, ensureParent)
+	}
+	if !s.filter.keepFile(rel) {
+		return nil
+	}
+	dstInfo, err := os.Lstat(dst)
+	if isNotExistOrNotDir(err) {
+		if err = ensureParent(); err != nil {
+			return s.fail(dst, err)
+		}
+		s.addCopy(SyncActionCreate, rel, info)
+		return nil
+	} else if err != nil {
+		return s.fail(dst, err)
+	}
+	changed, err := s.changed(src, dst, info, dstInfo)
+	if err != nil || !changed {
+		return s.fail(src, err)
+	}
+	if err = ensureParent(); err != nil {
+		return s.fail(dst, err)
+	}
+	s.addCopy(SyncActionUpdate, rel, info)
+	return nil
+}
+
+// 判断源和目标是否不同
+func (s *syncer) changed(src, dst string, info, dstInfo os.FileInfo) (bool, error) {
+	if info.Mode().Type() != dstInfo.Mode().Type() {
+		return true, nil
+	}
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		srcTarget, err := os.Readlink(src)
+		if err != nil {
+			return false, err
+		}
+		dstTarget, err := os.Readlink(dst)
+		if err != nil {
+			return false, err
+		}
+		return srcTarget != dstTarget, nil
+	case !info.Mode().IsRegular():
+		return false, nil
+	}
+	if info.Size() != dstInfo.Size() {
+		return true, nil
+	}
+	if s.syncOpts.Compare != SyncCompareChecksum {
+		return !info.ModTime().Equal(dstInfo.ModTime()), nil
+	}
+	srcSum, err := CalcFileHash(src, s.syncOpts.Algorithm)
+	if err != nil {
+		return false, err
+	}
+	dstSum, err := CalcFileHash(dst, s.syncOpts.Algorithm)
+	if err != nil {
+		return false, err
+	}
+	return srcSum[s.syncOpts.Algorithm] != dstSum[s.syncOpts.Algorithm], nil
+}
+
+// 目标目录中源目录已不存在的条目计划删除; 目标不是目录时会被整体替换, 无需删除其中的条目
+func (s *syncer) planDeletes(dst, rel string, names map[string]bool) error {
+	dstInfo, err := os.Lstat(dst)
+	if isNotExistOrNotDir(err) {
+		return nil
+	} else if err != nil {
+		return s.fail(dst, err)
+	}
+	if !dstInfo.IsDir() {
+		return nil
+	}
+	entries, err := os.ReadDir(dst)
+	if err != nil {
+		return s.fail(dst, err)
+	}
+	for _, entry := range entries {
+		if names[entry.Name()] {
+			continue
+		}
+		entryRel := path.Join(rel, entry.Name())
+		if s.filter.exclude.Match(entryRel, entry.IsDir()) {
+			continue
+		}
+		s.deletes = append(s.deletes, SyncAction{Type: SyncActionDelete, Path: entryRel, IsDir: entry.IsDir()})
+	}
+	return nil
+}
+
+// 目标路径不存在, 或者上级路径在目标中是文件(将被替换为目录)
+func isNotExistOrNotDir(err error) bool {
+	return os.IsNotExist(err) || errors.Is(err, syscall.ENOTDIR)
+}
+
+func (s *syncer) execute() error {
+	for _, action := range s.deletes {
+		if err := s.ctx.Err(); err != nil {
+			return err
+		}
+		dst := filepath.Join(s.dstRoot, filepath.FromSlash(action.Path))
+		if err := s.fail(dst, os.RemoveAll(dst)); err != nil {
+			return err
+		}
+	}
+	for _, action := range s.copies {
+		if err := s.ctx.Err(); err != nil {
+			return err
+		}
+		dst := filepath.Join(s.dstRoot, filepath.FromSlash(action.Path))
+		if err := s.fail(dst, s.executeCopy(action)); err != nil {
+			return err
+		}
+	}
+	// 目录的元数据在其内容同步完成后再设置
+	for i := len(s.dirs) - 1; i >= 0; i-- {
+		rel := s.dirs[i]
+		src := filepath.Join(s.srcRoot, filepath.FromSlash(rel))
+		dst := filepath.Join(s.dstRoot, filepath.FromSlash(rel))
+		if err := s.fail(dst, s.applyMeta(src, dst, s.infos[rel])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *syncer) executeCopy(action SyncAction) error {
+	info := s.infos[action.Path]
+	src := filepath.Join(s.srcRoot, filepath.FromSlash(action.Path))
+	dst := filepath.Join(s.dstRoot, filepath.FromSlash(action.Path))
+	// 类型发生变化时先删除目标, 例如目录变成了文件
+	if action.Type == SyncActionUpdate {
+		if dstInfo, err := os.Lstat(dst); err == nil && dstInfo.Mode().Type() != info.Mode().Type() {
+			if err = os.RemoveAll(dst); err != nil {
+				return err
+			}
+		}
+	}
+	if info.IsDir() {
+		perm := os.FileMode(0777)
+		if s.opts.PreserveMode {
+			perm = info.Mode().Perm() | 0700
+		}
+		return os.MkdirAll(dst, perm)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	var err error
+	switch {
+	case info.Mode().IsRegular():
+		err = s.copyFile(src, dst, info)
+	case info.Mode()&os.ModeSymlink != 0:
+		err = s.copySymlink(src, dst)
+	default:
+		err = s.copySpecial(dst, info)
+	}
+	if err != nil {
+		return err
+	}
+	return s.applyMeta(src, dst, info)
+}
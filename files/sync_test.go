@@ -0,0 +1,105 @@
+package files
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		mustDo(t, os.MkdirAll(filepath.Dir(path), 0755))
+		mustDo(t, os.WriteFile(path, []byte(content), 0644))
+	}
+}
+
+func readTree(t *testing.T, root string) map[string]string {
+	t.Helper()
+	tree := map[string]string{}
+	paths, err := FindPaths(context.Background(), root, FindQuery{Types: []FileType{FileTypeFile}})
+	mustDo(t, err)
+	for _, path := range paths {
+		rel, err := filepath.Rel(root, path)
+		mustDo(t, err)
+		data, err := os.ReadFile(path)
+		mustDo(t, err)
+		tree[filepath.ToSlash(rel)] = string(data)
+	}
+	return tree
+}
+
+func TestSyncDirectory(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+	writeTree(t, src, map[string]string{"a.txt": "a", "conf/app.yml": "port: 80", "conf/new.yml": "new"})
+	mustDo(t, CopyDirectoryWithOptions(context.Background(), src, dst, CopyOptions{PreserveTimes: true}))
+	mustDo(t, os.Remove(filepath.Join(dst, "conf", "new.yml")))
+	writeTree(t, src, map[string]string{"conf/app.yml": "port: 8080"})
+	writeTree(t, dst, map[string]string{"old.txt": "old", "keep.log": "log"})
+	opts := SyncOptions{Delete: true, CopyOptions: CopyOptions{Exclude: []string{"*.log"}}}
+
+	dryRun := opts
+	dryRun.DryRun = true
+	before := readTree(t, dst)
+	actions, err := SyncDirectory(context.Background(), src, dst, dryRun)
+	if err != nil {
+		t.Fatalf("SyncDirectory(DryRun) error = %v", err)
+	}
+	want := []SyncAction{
+		{Type: SyncActionDelete, Path: "old.txt"},
+		{Type: SyncActionUpdate, Path: "conf/app.yml"},
+		{Type: SyncActionCreate, Path: "conf/new.yml"},
+	}
+	if !reflect.DeepEqual(actions, want) {
+		t.Errorf("SyncDirectory(DryRun) = %+v, want %+v", actions, want)
+	}
+	if after := readTree(t, dst); !reflect.DeepEqual(after, before) {
+		t.Errorf("DryRun修改了目标目录: %v", after)
+	}
+
+	if actions, err = SyncDirectory(context.Background(), src, dst, opts); err != nil {
+		t.Fatalf("SyncDirectory() error = %v", err)
+	}
+	if !reflect.DeepEqual(actions, want) {
+		t.Errorf("SyncDirectory() = %+v, want %+v", actions, want)
+	}
+	wantTree := map[string]string{"a.txt": "a", "conf/app.yml": "port: 8080", "conf/new.yml": "new", "keep.log": "log"}
+	if got := readTree(t, dst); !reflect.DeepEqual(got, wantTree) {
+		t.Errorf("同步后的目标目录 = %v, want %v", got, wantTree)
+	}
+	if actions, err = SyncDirectory(context.Background(), src, dst, opts); err != nil || len(actions) != 0 {
+		t.Errorf("再次同步 = %+v, %v, want 无动作", actions, err)
+	}
+}
+
+// 源目录在目标中对应的是普通文件时整体替换
+func TestSyncDirectoryReplaceFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+	writeTree(t, src, map[string]string{"conf/app.yml": "port: 80", "conf/sub/log.yml": "level: info"})
+	writeTree(t, dst, map[string]string{"conf": "i am a file"})
+
+	actions, err := SyncDirectory(context.Background(), src, dst, SyncOptions{Delete: true})
+	if err != nil {
+		t.Fatalf("SyncDirectory() error = %v", err)
+	}
+	want := []SyncAction{
+		{Type: SyncActionUpdate, Path: "conf", IsDir: true},
+		{Type: SyncActionCreate, Path: "conf/app.yml"},
+		{Type: SyncActionCreate, Path: "conf/sub", IsDir: true},
+		{Type: SyncActionCreate, Path: "conf/sub/log.yml"},
+	}
+	if !reflect.DeepEqual(actions, want) {
+		t.Errorf("SyncDirectory() = %+v, want %+v", actions, want)
+	}
+	wantTree := map[string]string{"conf/app.yml": "port: 80", "conf/sub/log.yml": "level: info"}
+	if got := readTree(t, dst); !reflect.DeepEqual(got, wantTree) {
+		t.Errorf("同步后的目标目录 = %v, want %v", got, wantTree)
+	}
+}
@@ -0,0 +1,98 @@
+package files
+
+import (
+	"context"
+	"os"
+	"path"
+	"strings"
+	"text/template"
+)
+
+// 模板文件的默认后缀
+const DefaultTemplateSuffix = ".tmpl"
+
+// 渲染模板目录的选项
+type RenderOptions struct {
+	// 拷贝选项, 同时作用于模板文件和普通文件; 权限和属主总是保留
+	CopyOptions
+	// 模板文件的后缀, 渲染后的文件名去掉该后缀; 默认为.tmpl
+	Suffix string
+	// 模板中可以使用的自定义函数
+	Funcs template.FuncMap
+	// 模板的分隔符, 默认为 {{ 和 }}
+	LeftDelim  string
+	RightDelim string
+	// 允许使用数据中不存在的键, 输出<no value>; 默认不存在的键会导致渲染失败
+	AllowMissingKeys bool
+}
+
+/*
+渲染模板目录, 用于按主机生成配置文件
+1. 源目录下以Suffix结尾的文件使用text/template渲染, 写入去掉后缀的目标文件, 如 app.yml.tmpl -> app.yml
+2. 其他文件原样拷贝, 目录结构、文件权限和属主与源目录保持一致
+3. data可以是结构体或map, 默认引用不存在的键时返回错误, 避免生成缺少配置的文件
+4. 存在同名模板的普通文件会被跳过, 如同时存在 app.yml 和 app.yml.tmpl 时只写入渲染结果
+5. 模板文件总是重新渲染, Overwrite为OverwriteIfNewer时对模板不生效; OverwriteNever时已存在的目标文件不会被覆盖
+6. 每个文件先写入临时文件再重命名, 渲染失败不会留下不完整的配置文件
+*/
+func RenderTemplateDir(ctx context.Context, srcDir, dstDir string, data interface{}, opts RenderOptions) error {
+	copyOpts := opts.CopyOptions
+	copyOpts.PreserveMode = true
+	copyOpts.PreserveOwner = true
+	renderer := &templateRenderer{data: data, opts: opts}
+	if renderer.opts.Suffix == "" {
+		renderer.opts.Suffix = DefaultTemplateSuffix
+	}
+	return copyDirectory(ctx, srcDir, dstDir, copyOpts, renderer)
+}
+
+type templateRenderer struct {
+	data interface{}
+	opts RenderOptions
+}
+
+func (r *templateRenderer) match(rel string) bool {
+	return strings.HasSuffix(rel, r.opts.Suffix) && len(path.Base(rel)) > len(r.opts.Suffix)
+}
+
+// 模板文件渲染后的目标路径
+func (r *templateRenderer) target(dst string) string {
+	return strings.TrimSuffix(dst, r.opts.Suffix)
+}
+
+// 普通文件是否与同目录下的模板渲染结果同名, 模板被过滤规则排除时不算
+func (r *templateRenderer) shadowed(src, rel string, filter *pathFilter) bool {
+	if !filter.keepFile(rel + r.opts.Suffix) {
+		return false
+	}
+	info, err := os.Stat(src + r.opts.Suffix)
+	return err == nil && info.Mode().IsRegular()
+}
+
+func (r *templateRenderer) render(src, dst, rel string, info os.FileInfo) error {
+	content, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	missingKey := "missingkey=error"
+	if r.opts.AllowMissingKeys {
+		missingKey = "missingkey=default"
+	}
+	tmpl, err := template.New(rel).
+		Option(missingKey).
+		Delims(r.opts.LeftDelim, r.opts.RightDelim).
+		Funcs(r.opts.Funcs).
+		Parse(string(content))
+	if err != nil {
+		return err
+	}
+	f, err := NewAtomicFile(dst, AtomicWriteOptions{Perm: info.Mode().Perm()})
+	if err != nil {
+		return err
+	}
+	defer f.Abort()
+	if err = tmpl.Execute(f, r.data); err != nil {
+		return err
+	}
+	return f.Commit()
+}
@@ -0,0 +1,98 @@
+package files
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestRenderTemplateDir(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	writeTree(t, src, map[string]string{
+		"conf/app.yml.tmpl": "host: {{ .Host }}\nport: {{ .Port }}\n",
+		"conf/app.yml":      "host: placeholder\n",
+		"bin/run.sh.tmpl":   "exec {{ upper .Name }}\n",
+		"static.txt":        "{{ .Host }} 原样拷贝\n",
+	})
+	mustDo(t, os.Chmod(filepath.Join(src, "bin", "run.sh.tmpl"), 0750))
+	funcs := template.FuncMap{"upper": strings.ToUpper}
+	data := map[string]interface{}{"Host": "node1", "Port": 8080, "Name": "app"}
+
+	for _, overwrite := range []OverwritePolicy{OverwriteAlways, OverwriteNever} {
+		dst := filepath.Join(dir, "dst", strings.Repeat("x", int(overwrite)+1))
+		opts := RenderOptions{Funcs: funcs, CopyOptions: CopyOptions{Overwrite: overwrite}}
+		if err := RenderTemplateDir(context.Background(), src, dst, data, opts); err != nil {
+			t.Fatalf("RenderTemplateDir(Overwrite=%d) error = %v", overwrite, err)
+		}
+		want := map[string]string{
+			"conf/app.yml": "host: node1\nport: 8080\n",
+			"bin/run.sh":   "exec APP\n",
+			"static.txt":   "{{ .Host }} 原样拷贝\n",
+		}
+		if got := readTree(t, dst); !reflect.DeepEqual(got, want) {
+			t.Errorf("RenderTemplateDir(Overwrite=%d) = %v, want %v", overwrite, got, want)
+		}
+		info, err := os.Stat(filepath.Join(dst, "bin", "run.sh"))
+		mustDo(t, err)
+		if info.Mode().Perm() != 0750 {
+			t.Errorf("run.sh mode = %v, want %v", info.Mode().Perm(), os.FileMode(0750))
+		}
+	}
+}
+
+// 模板的修改时间不变而数据变化时, OverwriteIfNewer仍然重新渲染
+func TestRenderTemplateDirIfNewer(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+	writeTree(t, src, map[string]string{"app.yml.tmpl": "port: {{ .Port }}\n"})
+	opts := RenderOptions{CopyOptions: CopyOptions{Overwrite: OverwriteIfNewer, PreserveTimes: true}}
+	mustDo(t, RenderTemplateDir(context.Background(), src, dst, map[string]int{"Port": 80}, opts))
+	mustDo(t, RenderTemplateDir(context.Background(), src, dst, map[string]int{"Port": 8080}, opts))
+	if got := readTree(t, dst)["app.yml"]; got != "port: 8080\n" {
+		t.Errorf("app.yml = %q, want %q", got, "port: 8080\n")
+	}
+}
+
+func TestRenderTemplateDirMissingKey(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	writeTree(t, src, map[string]string{"app.yml.tmpl": "port: {{ .Port }}\n"})
+	type config struct{ Host string }
+
+	tests := []struct {
+		name    string
+		data    interface{}
+		opts    RenderOptions
+		want    string
+		wantErr bool
+	}{
+		{"map缺少键", map[string]string{"Host": "node1"}, RenderOptions{}, "", true},
+		{"结构体缺少字段", config{Host: "node1"}, RenderOptions{}, "", true},
+		{"允许缺少键", map[string]string{"Host": "node1"}, RenderOptions{AllowMissingKeys: true}, "port: <no value>\n", false},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := filepath.Join(dir, "dst", strings.Repeat("x", i+1))
+			err := RenderTemplateDir(context.Background(), src, dst, tt.data, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RenderTemplateDir() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				// 渲染失败时不能留下不完整的文件
+				if _, err := os.Stat(filepath.Join(dst, "app.yml")); !os.IsNotExist(err) {
+					t.Errorf("渲染失败后目标文件仍然存在: %v", err)
+				}
+				return
+			}
+			if got := readTree(t, dst)["app.yml"]; got != tt.want {
+				t.Errorf("app.yml = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 默认的事件合并时间
+const DefaultWatchDebounce = 100 * time.Millisecond
+
+var (
+	ErrWatchUnsupported = errors.New("当前操作系统不支持监听文件变化")
+	ErrWatchOverflow    = errors.New("事件队列溢出, 部分事件已丢失")
+	ErrWatchClosed      = errors.New("监听已结束")
+)
+
+// 文件变化的类型, 合并后的事件可能同时包含多种类型
+type WatchOp uint32
+
+const (
+	WatchCreate WatchOp = 1 << iota // 创建, 包括移动到监听目录中
+	WatchWrite                      // 写入
+	WatchRemove                     // 删除
+	WatchRename                     // 重命名或移出监听目录
+	WatchChmod                      // 权限、属主、时间等元数据变化
+)
+
+func (op WatchOp) String() string {
+	var names []string
+	for _, item := range []struct {
+		op   WatchOp
+		name string
+	}{{WatchCreate, "CREATE"}, {WatchWrite, "WRITE"}, {WatchRemove, "REMOVE"}, {WatchRename, "RENAME"}, {WatchChmod, "CHMOD"}} {
+		if op&item.op != 0 {
+			names = append(names, item.name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// 文件变化事件
+type WatchEvent struct {
+	Path  string  `json:"path"`
+	Op    WatchOp `json:"op"`
+	IsDir bool    `json:"is_dir"`
+}
+
+// 监听选项
+type WatchOptions struct {
+	// 递归监听目录下的全部子目录, 包括之后新建的子目录
+	Recursive bool
+	// gitignore风格的包含规则, 相对于监听的目录匹配, 监听单个文件时匹配文件名; 为空时包含全部
+	Include []string
+	// gitignore风格的排除规则, 被排除的目录不会被递归监听
+	Exclude []string
+	// 关注的事件类型, 0表示全部
+	Ops WatchOp
+	// 同一路径在该时间内的多个事件合并为一个, Op为这些事件的并集; 0为DefaultWatchDebounce, 负数不合并
+	Debounce time.Duration
+	// 非致命错误的回调, 如事件队列溢出、新建的子目录无法监听等, 在监听的goroutine中同步调用
+	OnError func(err error)
+}
+
+// 文件监听器
+type Watcher struct {
+	backend *watchBackend
+	events  chan WatchEvent
+	done    chan struct{}
+	err     error
+}
+
+/*
+监听文件或目录的变化, 直到ctx取消
+1. 监听单个文件时, 文件被重命名或删除后不再监听; 需要监听以重命名方式原子替换的配置文件时, 请监听其所在目录并用Include过滤
+2. 递归监听时, 新建子目录中在开始监听前已经创建的文件会补发CREATE事件
+*/
+func Watch(ctx context.Context, opts WatchOptions, paths ...string) (*Watcher, error) {
+	if opts.Ops == 0 {
+		opts.Ops = WatchCreate | WatchWrite | WatchRemove | WatchRename | WatchChmod
+	}
+	if opts.Debounce == 0 {
+		opts.Debounce = DefaultWatchDebounce
+	}
+	filter, err := newPathFilter(opts.Include, opts.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	backend, err := newWatchBackend(ctx, opts, filter)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		if err = backend.add(path); err != nil {
+			backend.close()
+			return nil, err
+		}
+	}
+
+	w := &Watcher{backend: backend, events: make(chan WatchEvent), done: make(chan struct{})}
+	raw := make(chan WatchEvent, 256)
+	go func() {
+		err := backend.run(raw)
+		backend.close()
+		if err != nil && ctx.Err() == nil {
+			w.err = err
+		}
+		close(raw)
+	}()
+	go func() {
+		defer close(w.done)
+		defer close(w.events)
+		debounceEvents(ctx, raw, w.events, opts.Debounce)
+	}()
+	return w, nil
+}
+
+// 合并后的事件, 监听结束后关闭
+func (w *Watcher) Events() <-chan WatchEvent {
+	return w.events
+}
+
+// 等待监听结束, 返回导致结束的错误; ctx取消属于正常结束, 返回nil
+func (w *Watcher) Wait() error {
+	<-w.done
+	return w.err
+}
+
+// 增加监听的文件或目录, 监听结束后返回ErrWatchClosed
+func (w *Watcher) Add(path string) error {
+	return w.backend.add(path)
+}
+
+// 取消监听文件或目录, 递归监听的目录会同时取消其全部子目录
+func (w *Watcher) Remove(path string) error {
+	return w.backend.remove(path)
+}
+
+type pendingWatchEvent struct {
+	event WatchEvent
+	last  time.Time
+}
+
+// 合并同一路径在debounce时间内的事件, 同时到期的事件按路径第一次出现的顺序输出
+func debounceEvents(ctx context.Context, in <-chan WatchEvent, out chan<- WatchEvent, debounce time.Duration) {
+	send := func(event WatchEvent) bool {
+		select {
+		case out <- event:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	if debounce < 0 {
+		for event := range in {
+			if !send(event) {
+				return
+			}
+		}
+		return
+	}
+
+	pending := map[string]*pendingWatchEvent{}
+	var order []string
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	for {
+		select {
+		case event, ok := <-in:
+			if !ok {
+				return
+			}
+			if p, exists := pending[event.Path]; exists {
+				p.event.Op |= event.Op
+				p.event.IsDir = event.IsDir
+				p.last = time.Now()
+				continue
+			}
+			if len(pending) == 0 {
+				timer.Reset(debounce)
+			}
+			pending[event.Path] = &pendingWatchEvent{event: event, last: time.Now()}
+			order = append(order, event.Path)
+		case now := <-timer.C:
+			var next time.Duration
+			remaining := order[:0]
+			for _, path := range order {
+				p := pending[path]
+				if wait := p.last.Add(debounce).Sub(now); wait > 0 {
+					remaining = append(remaining, path)
+					if next == 0 || wait < next {
+						next = wait
+					}
+					continue
+				}
+				delete(pending, path)
+				if !send(p.event) {
+					return
+				}
+			}
+			order = remaining
+			if len(order) > 0 {
+				timer.Reset(next)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// 事件路径相对于监听根目录的路径, 用于Include和Exclude匹配
+func watchRelPath(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
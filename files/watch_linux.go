@@ -0,0 +1,282 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const inotifyWatchMask = unix.IN_CREATE | unix.IN_MODIFY | unix.IN_DELETE | unix.IN_DELETE_SELF |
+	unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_MOVE_SELF | unix.IN_ATTRIB
+
+// 基于inotify的监听实现
+type watchBackend struct {
+	ctx     context.Context
+	opts    WatchOptions
+	filter  *pathFilter
+	fd      int
+	file    *os.File
+	once    sync.Once
+	mu      sync.Mutex
+	closed  bool // 关闭后fd可能已被复用, 不能再调用inotify_add_watch等; ctx取消后同样视为已关闭
+	watches map[int]*inotifyWatch
+	wds     map[string]int
+	raw     chan<- WatchEvent
+}
+
+type inotifyWatch struct {
+	path   string
+	root   string // Include和Exclude规则匹配的根目录, 监听单个文件时为其所在目录
+	isDir  bool
+	isRoot bool // 通过Add添加的路径
+}
+
+func newWatchBackend(ctx context.Context, opts WatchOptions, filter *pathFilter) (*watchBackend, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	// 非阻塞的fd会交给Go的poller, 关闭文件时阻塞中的Read会立即返回; 不能调用Fd(), 否则会被切换回阻塞模式
+	return &watchBackend{
+		ctx:     ctx,
+		opts:    opts,
+		filter:  filter,
+		fd:      fd,
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		watches: map[int]*inotifyWatch{},
+		wds:     map[string]int{},
+	}, nil
+}
+
+func (b *watchBackend) add(path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return b.addWatch(&inotifyWatch{path: path, root: filepath.Dir(path), isRoot: true})
+	}
+	return b.addTree(path, path, false)
+}
+
+// 监听目录, 递归模式下同时监听其全部子目录; emitCreates为true时为目录下已有的条目补发CREATE事件
+func (b *watchBackend) addTree(dir, root string, emitCreates bool) error {
+	if err := b.addWatch(&inotifyWatch{path: dir, root: root, isDir: true, isRoot: dir == root}); err != nil {
+		return err
+	}
+	if !b.opts.Recursive && !emitCreates {
+		return nil
+	}
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// 遍历过程中被删除的条目直接忽略
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		if d.IsDir() && b.filter.skipDir(watchRelPath(root, path)) {
+			return filepath.SkipDir
+		}
+		if emitCreates {
+			b.emit(path, root, WatchCreate, d.IsDir())
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if !b.opts.Recursive {
+			return filepath.SkipDir
+		}
+		return b.addWatch(&inotifyWatch{path: path, root: root, isDir: true})
+	})
+}
+
+func (b *watchBackend) addWatch(w *inotifyWatch) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed || b.ctx.Err() != nil {
+		return ErrWatchClosed
+	}
+	wd, err := unix.InotifyAddWatch(b.fd, w.path, inotifyWatchMask)
+	if err != nil {
+		return &os.PathError{Op: "inotify_add_watch", Path: w.path, Err: err}
+	}
+	if old, ok := b.watches[wd]; ok {
+		// 同一个inode重复添加时内核返回相同的wd
+		delete(b.wds, old.path)
+		w.isRoot = w.isRoot || old.isRoot
+	}
+	b.watches[wd] = w
+	b.wds[w.path] = wd
+	return nil
+}
+
+func (b *watchBackend) remove(path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed || b.ctx.Err() != nil {
+		return ErrWatchClosed
+	}
+	if _, ok := b.wds[path]; !ok {
+		return &os.PathError{Op: "inotify_rm_watch", Path: path, Err: os.ErrNotExist}
+	}
+	for watchPath, wd := range b.wds {
+		if watchPath != path && !strings.HasPrefix(watchPath, path+string(filepath.Separator)) {
+			continue
+		}
+		unix.InotifyRmWatch(b.fd, uint32(wd))
+		delete(b.wds, watchPath)
+		delete(b.watches, wd)
+	}
+	return nil
+}
+
+// 可以多次调用, 文件只关闭一次
+func (b *watchBackend) close() {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+	b.once.Do(func() { b.file.Close() })
+}
+
+// 读取inotify事件直到ctx取消
+func (b *watchBackend) run(raw chan<- WatchEvent) error {
+	b.raw = raw
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-b.ctx.Done():
+			b.close()
+		case <-stop:
+		}
+	}()
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := b.file.Read(buf)
+		if err != nil {
+			if b.ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + unix.SizeofInotifyEvent
+			name := strings.TrimRight(string(buf[nameStart:nameStart+int(event.Len)]), "\x00")
+			b.handle(int(event.Wd), event.Mask, name)
+			offset = nameStart + int(event.Len)
+		}
+	}
+}
+
+func (b *watchBackend) handle(wd int, mask uint32, name string) {
+	if mask&unix.IN_Q_OVERFLOW != 0 {
+		b.reportError(ErrWatchOverflow)
+		return
+	}
+	b.mu.Lock()
+	w, ok := b.watches[wd]
+	if ok && mask&unix.IN_IGNORED != 0 {
+		// 被监听的文件已删除或监听已被移除
+		delete(b.watches, wd)
+		if b.wds[w.path] == wd {
+			delete(b.wds, w.path)
+		}
+	}
+	b.mu.Unlock()
+	if !ok || mask&unix.IN_IGNORED != 0 {
+		return
+	}
+
+	path := w.path
+	isDir := mask&unix.IN_ISDIR != 0
+	if name != "" {
+		path = filepath.Join(w.path, name)
+	} else {
+		isDir = w.isDir
+		// 子目录自身的删除和移动事件与其上级目录中的事件重复, 只保留根路径的
+		if mask&(unix.IN_DELETE_SELF|unix.IN_MOVE_SELF) != 0 && !w.isRoot {
+			return
+		}
+		if mask&unix.IN_MOVE_SELF != 0 {
+			// 移走之后的事件已经不属于原路径
+			b.remove(w.path)
+		}
+	}
+
+	var op WatchOp
+	if mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+		op |= WatchCreate
+	}
+	if mask&unix.IN_MODIFY != 0 {
+		op |= WatchWrite
+	}
+	if mask&(unix.IN_DELETE|unix.IN_DELETE_SELF) != 0 {
+		op |= WatchRemove
+	}
+	if mask&(unix.IN_MOVED_FROM|unix.IN_MOVE_SELF) != 0 {
+		op |= WatchRename
+	}
+	if mask&unix.IN_ATTRIB != 0 {
+		op |= WatchChmod
+	}
+	b.emit(path, w.root, op, isDir)
+
+	// 移出的子目录不再监听, 移入的子目录按新建处理
+	if isDir && name != "" && mask&unix.IN_MOVED_FROM != 0 {
+		b.remove(path)
+	}
+	if isDir && name != "" && op&WatchCreate != 0 && b.opts.Recursive && !b.filter.skipDir(watchRelPath(w.root, path)) {
+		if err := b.addTree(path, w.root, true); err != nil && !os.IsNotExist(err) && !errors.Is(err, ErrWatchClosed) {
+			b.reportError(err)
+		}
+	}
+}
+
+// 按事件类型和路径规则过滤后输出事件
+func (b *watchBackend) emit(path, root string, op WatchOp, isDir bool) {
+	op &= b.opts.Ops
+	if op == 0 {
+		return
+	}
+	rel := watchRelPath(root, path)
+	if rel != "." {
+		if isDir {
+			if b.filter.skipDir(rel) || !b.filter.include.Empty() && !b.filter.include.Match(rel, true) {
+				return
+			}
+		} else if !b.filter.keepFile(rel) {
+			return
+		}
+	}
+	select {
+	case b.raw <- WatchEvent{Path: path, Op: op, IsDir: isDir}:
+	case <-b.ctx.Done():
+	}
+}
+
+func (b *watchBackend) reportError(err error) {
+	if b.opts.OnError != nil {
+		b.opts.OnError(err)
+	}
+}
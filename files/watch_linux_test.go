@@ -0,0 +1,55 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchRecursive(t *testing.T) {
+	dir := t.TempDir()
+	mustDo(t, os.MkdirAll(filepath.Join(dir, "tmp"), 0755))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w, err := Watch(ctx, WatchOptions{Recursive: true, Include: []string{"*.yml"}, Exclude: []string{"tmp/"}, Debounce: 20 * time.Millisecond}, dir)
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+
+	// 新建子目录中在开始监听前已经创建的文件也会收到CREATE事件
+	writeTree(t, dir, map[string]string{
+		"conf/app.yml": "port: 8080\n",
+		"conf/app.txt": "ignored",
+		"tmp/x.yml":    "ignored",
+	})
+	target := filepath.Join(dir, "conf", "app.yml")
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case event := <-w.Events():
+			if filepath.Base(event.Path) != "app.yml" && !event.IsDir {
+				t.Errorf("收到被过滤的事件: %+v", event)
+			}
+			if event.Path == target && event.Op&WatchCreate != 0 {
+				cancel()
+				for range w.Events() {
+				}
+				if err = w.Wait(); err != nil {
+					t.Errorf("Wait() = %v, want nil", err)
+				}
+				if err = w.Add(dir); !errors.Is(err, ErrWatchClosed) {
+					t.Errorf("监听结束后Add() = %v, want ErrWatchClosed", err)
+				}
+				if err = w.Remove(dir); !errors.Is(err, ErrWatchClosed) {
+					t.Errorf("监听结束后Remove() = %v, want ErrWatchClosed", err)
+				}
+				return
+			}
+		case <-deadline:
+			t.Fatalf("等待 %s 的CREATE事件超时", target)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+//go:build !linux
+// +build !linux
+
+package files
+
+import "context"
+
+type watchBackend struct{}
+
+func newWatchBackend(ctx context.Context, opts WatchOptions, filter *pathFilter) (*watchBackend, error) {
+	return nil, ErrWatchUnsupported
+}
+
+func (b *watchBackend) add(path string) error {
+	return ErrWatchUnsupported
+}
+
+func (b *watchBackend) remove(path string) error {
+	return ErrWatchUnsupported
+}
+
+func (b *watchBackend) close() {}
+
+func (b *watchBackend) run(raw chan<- WatchEvent) error {
+	return ErrWatchUnsupported
+}
@@ -0,0 +1,36 @@
+package files
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDebounceEvents(t *testing.T) {
+	in := make(chan WatchEvent)
+	out := make(chan WatchEvent)
+	go debounceEvents(context.Background(), in, out, 50*time.Millisecond)
+	in <- WatchEvent{Path: "a", Op: WatchCreate}
+	in <- WatchEvent{Path: "b", Op: WatchWrite}
+	in <- WatchEvent{Path: "a", Op: WatchWrite}
+
+	got := map[string]WatchEvent{}
+	for len(got) < 2 {
+		select {
+		case event := <-out:
+			got[event.Path] = event
+		case <-time.After(5 * time.Second):
+			t.Fatalf("等待事件超时, 已收到 %v", got)
+		}
+	}
+	close(in)
+	// 同一路径的事件合并
+	want := map[string]WatchEvent{"a": {Path: "a", Op: WatchCreate | WatchWrite}, "b": {Path: "b", Op: WatchWrite}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("debounceEvents() = %v, want %v", got, want)
+	}
+	if got := (WatchCreate | WatchWrite).String(); got != "CREATE|WRITE" {
+		t.Errorf("WatchOp.String() = %s, want CREATE|WRITE", got)
+	}
+}
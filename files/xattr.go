@@ -0,0 +1,75 @@
+package files
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+var ErrXattrNotFound = errors.New("扩展属性不存在")
+
+// SELinux安全上下文对应的扩展属性
+const XattrSELinux = "security.selinux"
+
+/*
+扩展属性的读写, 仅支持Linux
+1. 路径为软链接时操作其指向的文件, 与getfattr、setfattr的默认行为一致
+2. 属性名需要带命名空间, 如 user.checksum、security.selinux
+3. 文件系统不支持扩展属性时返回的错误可以用errors.Is(err, syscall.ENOTSUP)判断
+*/
+
+// 列出文件的全部扩展属性名
+func ListXattrs(path string) ([]string, error) {
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, err
+	}
+	names, err := listXattrNames(real)
+	if err != nil {
+		return nil, &os.PathError{Op: "listxattr", Path: path, Err: err}
+	}
+	return names, nil
+}
+
+// 读取扩展属性, 不存在时返回ErrXattrNotFound
+func GetXattr(path, name string) ([]byte, error) {
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, err
+	}
+	value, err := getXattr(real, name)
+	if isXattrNotFound(err) {
+		return nil, fmt.Errorf("%w: %s %s", ErrXattrNotFound, path, name)
+	} else if err != nil {
+		return nil, &os.PathError{Op: "getxattr", Path: path, Err: err}
+	}
+	return value, nil
+}
+
+// 设置扩展属性, 已存在时覆盖
+func SetXattr(path, name string, value []byte) error {
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+	if err = setXattr(real, name, value); err != nil {
+		return &os.PathError{Op: "setxattr", Path: path, Err: err}
+	}
+	return nil
+}
+
+// 删除扩展属性, 不存在时返回ErrXattrNotFound
+func RemoveXattr(path, name string) error {
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+	err = removeXattr(real, name)
+	if isXattrNotFound(err) {
+		return fmt.Errorf("%w: %s %s", ErrXattrNotFound, path, name)
+	} else if err != nil {
+		return &os.PathError{Op: "removexattr", Path: path, Err: err}
+	}
+	return nil
+}
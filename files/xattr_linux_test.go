@@ -0,0 +1,59 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestXattr(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file")
+	link := filepath.Join(dir, "link")
+	mustDo(t, os.WriteFile(path, []byte("data"), 0644))
+	mustDo(t, os.Symlink("file", link))
+	if err := SetXattr(path, "user.checksum", []byte("abc")); isXattrUnsupported(err) {
+		t.Skipf("文件系统不支持扩展属性: %v", err)
+	} else if err != nil {
+		t.Fatalf("SetXattr() error = %v", err)
+	}
+
+	// 软链接操作其指向的文件
+	value, err := GetXattr(link, "user.checksum")
+	if err != nil || string(value) != "abc" {
+		t.Errorf("GetXattr() = %q, %v, want abc", value, err)
+	}
+	mustDo(t, SetXattr(link, "user.checksum", []byte("def")))
+	names, err := ListXattrs(path)
+	mustDo(t, err)
+	found := false
+	for _, name := range names {
+		found = found || name == "user.checksum"
+	}
+	if !found {
+		t.Errorf("ListXattrs() = %v, want 包含 user.checksum", names)
+	}
+
+	// CopyDirectoryWithOptions保留扩展属性
+	dst := filepath.Join(t.TempDir(), "dst")
+	mustDo(t, CopyDirectoryWithOptions(context.Background(), dir, dst, CopyOptions{PreserveXattrs: true}))
+	if value, err = GetXattr(filepath.Join(dst, "file"), "user.checksum"); err != nil || string(value) != "def" {
+		t.Errorf("拷贝后 GetXattr() = %q, %v, want def", value, err)
+	}
+
+	mustDo(t, RemoveXattr(path, "user.checksum"))
+	if _, err = GetXattr(path, "user.checksum"); !errors.Is(err, ErrXattrNotFound) {
+		t.Errorf("GetXattr() error = %v, want %v", err, ErrXattrNotFound)
+	}
+	if err = RemoveXattr(path, "user.checksum"); !errors.Is(err, ErrXattrNotFound) {
+		t.Errorf("RemoveXattr() error = %v, want %v", err, ErrXattrNotFound)
+	}
+	if caps, err := GetFileCaps(path); caps != nil || err != nil {
+		t.Errorf("GetFileCaps() = %v, %v, want nil", caps, err)
+	}
+	if acl, err := GetACL(path); acl != nil || err != nil {
+		t.Errorf("GetACL() = %v, %v, want nil", acl, err)
+	}
+}
@@ -5,11 +5,15 @@ go 1.19
 require (
 	github.com/duke-git/lancet/v2 v2.2.1
 	github.com/google/uuid v1.6.0
+	github.com/klauspost/compress v1.16.7
 	github.com/lestrrat-go/file-rotatelogs v2.4.0+incompatible
 	github.com/rifflock/lfshook v0.0.0-20180920164130-b9218ef580f5
 	github.com/shirou/gopsutil v3.21.11+incompatible
 	github.com/sirupsen/logrus v1.9.0
+	github.com/ulikunitz/xz v0.5.11
 	github.com/vishvananda/netlink v1.1.0
+	golang.org/x/crypto v0.9.0
+	golang.org/x/sys v0.8.0
 	golang.org/x/text v0.9.0
 )
 
@@ -23,5 +27,4 @@ require (
 	github.com/tklauser/numcpus v0.6.0 // indirect
 	github.com/vishvananda/netns v0.0.0-20191106174202-0a2b9b5464df // indirect
 	github.com/yusufpapurcu/wmi v1.2.2 // indirect
-	golang.org/x/sys v0.5.0 // indirect
 )
@@ -1,6 +1,7 @@
 package ps
 
 import (
+	"errors"
 	"strings"
 
 	"github.com/shirou/gopsutil/process"
@@ -70,3 +71,15 @@ func GetProcessInfoByPid(pid int32) (ProcessInfo, error) {
 	}
 	return processInfo, err
 }
+
+// 判断进程是否存在, 没有权限读取其他用户进程的全部信息时, 只要进程存在也返回true
+func IsProcessAlive(pid int32) (bool, error) {
+	_, err := GetProcessInfoByPid(pid)
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, process.ErrorProcessNotRunning) {
+		return false, nil
+	}
+	return process.PidExists(pid)
+}
@@ -1,9 +1,9 @@
 package request
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
 	"os"
@@ -29,29 +29,59 @@ func GetBaseUrlWithoutQueryString(rawUrl string) (string, error) {
 
 // HTTP协议下载文件存储到本地，若成功则返回 true, <nil>
 func HttpDownload(url string, savePath string) (bool, error) {
+	return HttpDownloadWithContext(context.Background(), url, savePath, nil)
+}
+
+// 支持取消和进度回调的HTTP下载，先写入同目录下的临时文件，下载完成后再原子替换savePath
+// 进度的总字节数取自Content-Length，服务端未返回时为-1
+func HttpDownloadWithContext(ctx context.Context, url string, savePath string, progress files.ProgressFunc) (bool, error) {
+	return HttpDownloadWithOptions(ctx, url, savePath, HttpDownloadOptions{Progress: progress})
+}
+
+// HTTP下载选项
+type HttpDownloadOptions struct {
+	// 进度回调，总字节数取自Content-Length，服务端未返回时为-1
+	Progress files.ProgressFunc
+	// 开始写入前按Content-Length检查保存目录所在文件系统的剩余空间，不足时返回files.ErrInsufficientSpace
+	// 服务端未返回Content-Length时不检查
+	CheckSpace bool
+}
+
+// 按选项下载文件，行为与HttpDownloadWithContext一致
+func HttpDownloadWithOptions(ctx context.Context, url string, savePath string, opts HttpDownloadOptions) (bool, error) {
 	saveDir := filepath.Dir(savePath)
 	err := files.CreateDirIfNotExist(saveDir, os.ModePerm)
 	if err != nil {
 		return false, err
 	}
-	save, err := os.Create(savePath)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return false, err
 	}
-	defer save.Close()
-	response, err := http.Get(url)
+	response, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return false, err
-	} else {
-		if response.StatusCode != 200 {
-			return false, errors.New(response.Status)
-		}
 	}
 	defer response.Body.Close()
+	if response.StatusCode != 200 {
+		return false, errors.New(response.Status)
+	}
+	if opts.CheckSpace && response.ContentLength > 0 {
+		if err = files.CheckDiskSpace(saveDir, response.ContentLength, 1); err != nil {
+			return false, err
+		}
+	}
 
-	_, err = io.Copy(save, response.Body)
+	save, err := files.NewAtomicFile(savePath, files.AtomicWriteOptions{})
 	if err != nil {
 		return false, err
 	}
+	defer save.Abort()
+	if _, err = files.CopyReaderWithContext(ctx, save, response.Body, response.ContentLength, savePath, opts.Progress); err != nil {
+		return false, err
+	}
+	if err = save.Commit(); err != nil {
+		return false, err
+	}
 	return true, err
 }
@@ -0,0 +1,60 @@
+package request
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/toddlerya/glue/files"
+)
+
+func TestHttpDownloadWithContext(t *testing.T) {
+	data := bytes.Repeat([]byte("glue"), 100000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/app.tar.gz" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	savePath := filepath.Join(dir, "sub", "app.tar.gz")
+	var last files.Progress
+	ok, err := HttpDownloadWithContext(context.Background(), server.URL+"/app.tar.gz", savePath, func(p files.Progress) { last = p })
+	if !ok || err != nil {
+		t.Fatalf("HttpDownloadWithContext() = %v, %v", ok, err)
+	}
+	if content, _ := os.ReadFile(savePath); !bytes.Equal(content, data) {
+		t.Errorf("下载的内容不一致")
+	}
+	if last.Done != int64(len(data)) || last.Total != int64(len(data)) {
+		t.Errorf("最后一次进度 = %+v, want Done = Total = %d", last, len(data))
+	}
+
+	// 下载失败时不会覆盖已有的文件, 也不会留下临时文件
+	ok, err = HttpDownload(server.URL+"/missing", savePath)
+	if ok || err == nil {
+		t.Errorf("HttpDownload(404) = %v, %v, want false, error", ok, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if ok, err = HttpDownloadWithContext(ctx, server.URL+"/app.tar.gz", savePath, nil); ok || !errors.Is(err, context.Canceled) {
+		t.Errorf("HttpDownloadWithContext(已取消) = %v, %v, want false, %v", ok, err, context.Canceled)
+	}
+	if content, _ := os.ReadFile(savePath); !bytes.Equal(content, data) {
+		t.Errorf("下载失败后已有的文件被修改")
+	}
+	entries, err := os.ReadDir(filepath.Dir(savePath))
+	if err != nil || len(entries) != 1 {
+		t.Errorf("保存目录中有%d个文件, %v, want 1", len(entries), err)
+	}
+}
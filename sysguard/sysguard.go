@@ -1,8 +1,12 @@
 package sysguard
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/toddlerya/glue/files"
@@ -38,6 +42,54 @@ func UnSetupService(systemdServiceConfig SystemdServiceConfig, deleteExporterWor
 	return err
 }
 
+// 校验服务名称, 名称会拼接到unit文件、启动脚本和锁文件的路径中, 不允许为.、..或包含路径分隔符
+func validateServiceName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\\x00") {
+		return fmt.Errorf("无效的服务名称: %q", name)
+	}
+	return nil
+}
+
+// 服务部署锁文件所在的目录, 不存在时使用系统临时目录
+var ServiceLockDir = "/var/lock"
+
+// 获取服务的部署锁, 同一服务同时只允许一个进程部署或卸载; timeout为0时一直等待直到ctx取消
+func LockService(ctx context.Context, serviceName string, timeout time.Duration) (*files.FileLock, error) {
+	if err := validateServiceName(serviceName); err != nil {
+		return nil, err
+	}
+	lockDir := ServiceLockDir
+	if !files.PathIsExist(lockDir) {
+		lockDir = os.TempDir()
+	}
+	lockPath := filepath.Join(lockDir, "sysguard-"+serviceName+".lock")
+	lock, err := files.LockFile(ctx, lockPath, files.LockOptions{Timeout: timeout})
+	if err != nil {
+		return nil, fmt.Errorf("获取服务%s的部署锁失败: %w", serviceName, err)
+	}
+	return lock, nil
+}
+
+// 持有服务部署锁期间自动选择部署模式部署服务
+func SetupServiceWithLock(systemdServiceConfig SystemdServiceConfig, timeout time.Duration) error {
+	lock, err := LockService(context.Background(), systemdServiceConfig.Name, timeout)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	return SetupService(systemdServiceConfig)
+}
+
+// 持有服务部署锁期间自动选择卸载模式卸载服务
+func UnSetupServiceWithLock(systemdServiceConfig SystemdServiceConfig, deleteExporterWorkingDirectory bool, timeout time.Duration) error {
+	lock, err := LockService(context.Background(), systemdServiceConfig.Name, timeout)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+	return UnSetupService(systemdServiceConfig, deleteExporterWorkingDirectory)
+}
+
 // 根据操作系统自动选择使用systemd还是SysVinit
 func AutoChoseSetupMode() (string, error) {
 	// 判断init模式
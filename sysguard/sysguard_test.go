@@ -0,0 +1,55 @@
+package sysguard
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLockServiceRejectsInvalidName(t *testing.T) {
+	lockDir := ServiceLockDir
+	ServiceLockDir = t.TempDir()
+	defer func() { ServiceLockDir = lockDir }()
+
+	for _, name := range []string{"", ".", "..", "../etc/app", "a/b", `a\b`, "a\x00b"} {
+		if lock, err := LockService(context.Background(), name, 0); err == nil {
+			lock.Unlock()
+			t.Errorf("LockService(%q) 应返回错误", name)
+		}
+	}
+	for _, name := range []string{"node_exporter", "node..exporter", "..app"} {
+		lock, err := LockService(context.Background(), name, 0)
+		if err != nil {
+			t.Fatalf("LockService(%q) error = %v", name, err)
+		}
+		lock.Unlock()
+	}
+}
+
+func TestGenSysVinitServiceScript(t *testing.T) {
+	scriptDir := ROOT_MODE_SYSVINIT_SCRIPT_PATH
+	ROOT_MODE_SYSVINIT_SCRIPT_PATH = t.TempDir()
+	defer func() { ROOT_MODE_SYSVINIT_SCRIPT_PATH = scriptDir }()
+
+	if err := GenSysVinitServiceScript(SystemdServiceConfig{Name: "node_exporter"}); err != nil {
+		t.Fatalf("GenSysVinitServiceScript() error = %v", err)
+	}
+	path := filepath.Join(ROOT_MODE_SYSVINIT_SCRIPT_PATH, "node_exporter")
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 权限受umask影响, 常见的umask 022下为0755, 至少所有者可执行
+	if info.Mode().Perm()&0100 == 0 {
+		t.Errorf("启动脚本权限 = %v, want 可执行", info.Mode().Perm())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "node_exporter") {
+		t.Errorf("启动脚本内容缺少服务名称:\n%s", data)
+	}
+}
@@ -2,7 +2,6 @@ package sysguard
 
 import (
 	"fmt"
-	"os"
 	"path/filepath"
 	"strings"
 	"text/template"
@@ -105,27 +104,28 @@ exit 0
 
 /*
 生成SysVinit的启动脚本
-1. 写入/etc/init.d/xxxxx
-2. chmod 755 /etc/init.d/xxxxx
+1. 以0755权限写入/etc/init.d/xxxxx
 */
 func GenSysVinitServiceScript(systemdServiceConfig SystemdServiceConfig) error {
+	if err := validateServiceName(systemdServiceConfig.Name); err != nil {
+		return err
+	}
 	tmpl, err := template.New(systemdServiceConfig.Name).Parse(sysVinitTemplate)
 	if err != nil {
 		return err
 	}
 	serviceScriptFilePath := filepath.Join(ROOT_MODE_SYSVINIT_SCRIPT_PATH, systemdServiceConfig.Name)
-	f, err := os.Create(serviceScriptFilePath)
+	// 原子写入, 渲染过程中出错或崩溃不会留下不完整的启动脚本; 新建时即带可执行权限
+	f, err := files.NewAtomicFile(serviceScriptFilePath, files.AtomicWriteOptions{Perm: 0755})
 	if err != nil {
 		return err
 	}
-	defer f.Close()
+	defer f.Abort()
 	err = tmpl.Execute(f, systemdServiceConfig)
 	if err != nil {
 		return err
 	}
-	// 对输出的文件增加可执行权限
-	err = os.Chmod(serviceScriptFilePath, 0755)
-	return err
+	return f.Commit()
 }
 
 /*
@@ -3,7 +3,6 @@ package sysguard
 import (
 	"fmt"
 	"io/fs"
-	"os"
 	"path/filepath"
 	"strings"
 	"text/template"
@@ -46,18 +45,25 @@ WantedBy=multi-user.target
 
 // 生成systemd配置文件
 func GenSystemdServiceConfigFile(systemdServiceConfig SystemdServiceConfig) error {
+	if err := validateServiceName(systemdServiceConfig.Name); err != nil {
+		return err
+	}
 	tmpl, err := template.New(systemdServiceConfig.Name).Parse(serviceTemplate)
 	if err != nil {
 		return err
 	}
 	serviceConfigFilePath := filepath.Join(SYSTEMD_SERVICE_PATH, systemdServiceConfig.Name+".service")
-	f, err := os.Create(serviceConfigFilePath)
+	// 原子写入, 渲染过程中出错或崩溃不会留下不完整的配置文件
+	f, err := files.NewAtomicFile(serviceConfigFilePath, files.AtomicWriteOptions{Perm: 0644, KeepMode: true, KeepOwner: true})
 	if err != nil {
 		return err
 	}
-	defer f.Close()
+	defer f.Abort()
 	err = tmpl.Execute(f, systemdServiceConfig)
-	return err
+	if err != nil {
+		return err
+	}
+	return f.Commit()
 }
 
 func PreSetupSystemdService() error {